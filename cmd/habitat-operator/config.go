@@ -0,0 +1,183 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	habv1beta2controller "github.com/habitat-sh/habitat-operator/pkg/controller/v1beta2"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	configAPIVersion = "operator.habitat.sh/v1alpha1"
+	configKind       = "OperatorConfig"
+)
+
+// OperatorConfig is the configuration file of the operator, passed with
+// --config. The flags setting the same settings override it. The operator
+// serves neither an admission webhook nor metrics, so the file has no settings
+// for them.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Namespaces are the namespaces watched by the operator. All namespaces
+	// are watched if it's empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector makes the operator watch the namespaces matching this
+	// label selector instead.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// HabitatSelector restricts the operator to the Habitats matching this
+	// label selector.
+	HabitatSelector string `json:"habitatSelector,omitempty"`
+	// ListenAddress is the address on which the operator's HTTP endpoints are
+	// served. They're disabled if it's empty.
+	ListenAddress string `json:"listenAddress,omitempty"`
+
+	Tuning `json:",inline"`
+
+	// HabitatDefaults are applied to the StatefulSets of new Habitats.
+	HabitatDefaults habv1beta2controller.HabitatDefaults `json:"habitatDefaults,omitempty"`
+	// FeatureGates enables or disables features of the operator.
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+func defaultOperatorConfig() OperatorConfig {
+	return OperatorConfig{
+		Tuning: defaultTuning(),
+	}
+}
+
+// loadConfig overrides the settings of c set in the YAML file path. Files
+// without apiVersion and kind, written for earlier versions of the operator,
+// are accepted too. Unknown settings are rejected rather than ignored.
+func loadConfig(path string, c *OperatorConfig) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&OperatorConfig{}); err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	if c.APIVersion != "" && c.APIVersion != configAPIVersion {
+		return fmt.Errorf("unsupported apiVersion %q in %s, expected %s", c.APIVersion, path, configAPIVersion)
+	}
+	if c.Kind != "" && c.Kind != configKind {
+		return fmt.Errorf("unsupported kind %q in %s, expected %s", c.Kind, path, configKind)
+	}
+
+	return nil
+}
+
+func (c OperatorConfig) validate() error {
+	if err := c.Tuning.validate(); err != nil {
+		return fmt.Errorf("invalid tuning: %v", err)
+	}
+
+	if c.NamespaceSelector != "" && len(c.Namespaces) > 0 {
+		return fmt.Errorf("namespaces and a namespace selector can't be combined")
+	}
+	for _, s := range []string{c.NamespaceSelector, c.HabitatSelector} {
+		if _, err := labels.Parse(s); err != nil {
+			return fmt.Errorf("invalid label selector %q: %v", s, err)
+		}
+	}
+
+	return habv1beta2controller.ValidateFeatureGates(c.FeatureGates)
+}
+
+// watchedNamespaces returns the sorted namespaces watched by the operator. A
+// single metav1.NamespaceAll stands for all namespaces.
+func (c OperatorConfig) watchedNamespaces() []string {
+	return parseNamespaces(strings.Join(c.Namespaces, ","))
+}
+
+// liveSettingsChanged returns whether new only differs from old in the
+// settings which are changed without restarting the controllers.
+func liveSettingsChanged(old, new OperatorConfig) bool {
+	return !reflect.DeepEqual(old.HabitatDefaults, new.HabitatDefaults) ||
+		!reflect.DeepEqual(old.FeatureGates, new.FeatureGates)
+}
+
+// restartNeeded returns whether the controllers need to be restarted for the
+// settings of new to take effect.
+func restartNeeded(old, new OperatorConfig) bool {
+	old.HabitatDefaults, new.HabitatDefaults = habv1beta2controller.HabitatDefaults{}, habv1beta2controller.HabitatDefaults{}
+	old.FeatureGates, new.FeatureGates = nil, nil
+
+	return !reflect.DeepEqual(old, new)
+}
+
+// watchConfig checks the file path for changes every interval until ctx is
+// done. On each change, it sends the settings returned by load to reloads,
+// unless they're invalid, in which case the error is logged.
+func watchConfig(ctx context.Context, path string, interval time.Duration, load func() (*FlagOpts, error), reloads chan<- *FlagOpts, logger log.Logger) {
+	// The file is polled rather than watched, as editors and ConfigMap volumes
+	// replace files in ways file system notifications don't follow reliably.
+	last, err := ioutil.ReadFile(path)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read config file", "path", path, "err", err)
+	}
+
+	go wait.Until(func() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to read config file", "path", path, "err", err)
+			return
+		}
+		if bytes.Equal(data, last) {
+			return
+		}
+		last = data
+
+		flags, err := load()
+		if err != nil {
+			level.Error(logger).Log("msg", "ignoring invalid config file, keeping the current settings", "path", path, "err", err)
+			return
+		}
+
+		level.Info(logger).Log("msg", "config file changed, reloading", "path", path)
+
+		select {
+		case reloads <- flags:
+		case <-ctx.Done():
+		}
+	}, interval, ctx.Done())
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	habv1beta2controller "github.com/habitat-sh/habitat-operator/pkg/controller/v1beta2"
+
+	"github.com/go-kit/kit/log"
+)
+
+// writeTestConfig replaces the file path, so that it's never seen partially
+// written.
+func writeTestConfig(t *testing.T, path, config string) {
+	if err := ioutil.WriteFile(path+".tmp", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, `
+apiVersion: operator.habitat.sh/v1alpha1
+kind: OperatorConfig
+namespaces: [team-a, team-b]
+workers: 4
+habitatDefaults:
+  filesInitImage: busybox
+  resources:
+    requests:
+      memory: 64Mi
+featureGates:
+  VolumeExpansion: false
+`)
+
+	c := defaultOperatorConfig()
+	if err := loadConfig(path, &c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.watchedNamespaces(); len(got) != 2 || got[0] != "team-a" || got[1] != "team-b" {
+		t.Errorf("watched namespaces = %v, want [team-a team-b]", got)
+	}
+	if c.Workers != 4 || c.KubeAPI.Burst != 10 {
+		t.Errorf("workers and burst = %d, %d, want 4, 10", c.Workers, c.KubeAPI.Burst)
+	}
+	if c.HabitatDefaults.FilesInitImage != "busybox" {
+		t.Errorf("files init image = %q, want busybox", c.HabitatDefaults.FilesInitImage)
+	}
+	if got := c.HabitatDefaults.Resources.Requests.Memory(); got.String() != "64Mi" {
+		t.Errorf("memory request = %s, want 64Mi", got.String())
+	}
+	if enabled, ok := c.FeatureGates[habv1beta2controller.FeatureGateVolumeExpansion]; !ok || enabled {
+		t.Errorf("feature gates = %v, want VolumeExpansion disabled", c.FeatureGates)
+	}
+
+	writeTestConfig(t, path, "apiVersion: operator.habitat.sh/v2\n")
+	c = defaultOperatorConfig()
+	if err := loadConfig(path, &c); err == nil {
+		t.Error("loadConfig() of an unsupported version returned no error")
+	}
+
+	writeTestConfig(t, path, "metrics:\n  address: :9090\n")
+	c = defaultOperatorConfig()
+	if err := loadConfig(path, &c); err == nil {
+		t.Error("loadConfig() of an unknown setting returned no error")
+	}
+}
+
+func TestOperatorConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*OperatorConfig)
+	}{
+		{"namespaces and selector", func(c *OperatorConfig) {
+			c.Namespaces = []string{"team-a"}
+			c.NamespaceSelector = "team=a"
+		}},
+		{"invalid selector", func(c *OperatorConfig) { c.HabitatSelector = "a b" }},
+		{"unknown feature gate", func(c *OperatorConfig) { c.FeatureGates = map[string]bool{"Unknown": true} }},
+		{"invalid tuning", func(c *OperatorConfig) { c.Workers = 0 }},
+	}
+
+	for _, tt := range tests {
+		c := defaultOperatorConfig()
+		tt.modify(&c)
+		if err := c.validate(); err == nil {
+			t.Errorf("%s: validate() returned no error", tt.name)
+		}
+	}
+}
+
+func TestRestartNeeded(t *testing.T) {
+	old := defaultOperatorConfig()
+
+	live := defaultOperatorConfig()
+	live.HabitatDefaults.FilesInitImage = "busybox"
+	live.FeatureGates = map[string]bool{habv1beta2controller.FeatureGatePodDisruptionBudgets: false}
+	if restartNeeded(old, live) {
+		t.Error("restartNeeded() = true for new Habitat defaults and feature gates, want false")
+	}
+	if !liveSettingsChanged(old, live) {
+		t.Error("liveSettingsChanged() = false for new Habitat defaults and feature gates, want true")
+	}
+
+	restart := defaultOperatorConfig()
+	restart.Namespaces = []string{"team-a"}
+	if !restartNeeded(old, restart) {
+		t.Error("restartNeeded() = false for new namespaces, want true")
+	}
+	if liveSettingsChanged(old, restart) {
+		t.Error("liveSettingsChanged() = true for new namespaces, want false")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, "workers: 1\n")
+
+	load := func() (*FlagOpts, error) {
+		flags := &FlagOpts{OperatorConfig: defaultOperatorConfig()}
+		if err := loadConfig(path, &flags.OperatorConfig); err != nil {
+			return nil, err
+		}
+		if flags.Workers < 1 {
+			return nil, errors.New("invalid workers")
+		}
+
+		return flags, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *FlagOpts)
+	watchConfig(ctx, path, 10*time.Millisecond, load, reloads, log.NewNopLogger())
+
+	// Invalid configs are ignored.
+	writeTestConfig(t, path, "workers: 0\n")
+	time.Sleep(50 * time.Millisecond)
+	writeTestConfig(t, path, "workers: 2\n")
+
+	select {
+	case flags := <-reloads:
+		if flags.Workers != 2 {
+			t.Errorf("reloaded workers = %d, want 2", flags.Workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchConfig() didn't reload the changed config")
+	}
+}
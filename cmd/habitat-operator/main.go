@@ -18,9 +18,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -41,8 +43,6 @@ import (
 	"github.com/habitat-sh/habitat-operator/pkg/version"
 )
 
-const resyncPeriod = 30 * time.Second
-
 type Clientsets struct {
 	KubeClientset          *kubernetes.Clientset
 	HabClientset           *habclientset.Clientset
@@ -51,16 +51,39 @@ type Clientsets struct {
 
 // FlagOpts struct is used to save all the flag values for operator
 type FlagOpts struct {
-	Namespace           string
+	OperatorConfig
 	AssumeCRDRegistered bool
+	DryRun              bool
+	ShardNamespace      string
+	ShardIdentity       string
+	ShardLeaseDuration  time.Duration
 }
 
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 5 * time.Second
+
 func run() int {
 	// Parse config flags.
 	kubeconfig := flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging.")
-	namespace := flag.String("namespace", metav1.NamespaceAll, "Specify namespace this Operator will be monitoring. (default: Monitors all namespaces)")
+	namespace := flag.String("namespace", metav1.NamespaceAll, "Specify namespace this Operator will be monitoring, or a comma-separated list of namespaces. (default: Monitors all namespaces)")
+	namespaceSelector := flag.String("namespace-selector", "", "Monitor the namespaces matching this label selector, e.g. \"team=a\". Can't be combined with --namespace.")
+	habitatSelector := flag.String("habitat-selector", "", "Only manage the Habitats matching this label selector. (default: Manages all Habitats)")
 	assumeCRDRegistered := flag.Bool("assume-crd-registered", false, "If cluster admin has already registered CRD then provide this flag with namespace flag.")
+	listenAddress := flag.String("listen-address", "", "Address on which to serve the operator's HTTP endpoints, e.g. \":8080\". (default: HTTP endpoints are disabled)")
+	dryRun := flag.Bool("dry-run", false, "Log the changes the operator would make to the resources of Habitats, without making them. The CRD must already be registered.")
+	shardNamespace := flag.String("shard-namespace", "", "Spread the Habitats across the replicas of the operator sharing the leases in this namespace. (default: Manages all Habitats)")
+	shardIdentity := flag.String("shard-identity", "", "Unique name of this replica of the operator, e.g. the name of its Pod. (default: the hostname)")
+	shardLeaseDuration := flag.Duration("shard-lease-duration", 15*time.Second, "How long a replica which stopped renewing its shard lease keeps its Habitats.")
+	configFile := flag.String("config", "", "Path to the YAML config file of the operator, which is reloaded when it changes. Flags override the settings of the file.")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of Habitats reconciled concurrently.")
+	resync := flag.Duration("resync-period", 30*time.Second, "How often the informers resync their cache.")
+	resyncPeriods := resyncPeriodsFlag{}
+	flag.Var(resyncPeriods, "resync-periods", "Comma-separated resync periods of the informers of some resources, e.g. \"pods=1m,secrets=5m\". (default: pods=1m)")
+	baseDelay := flag.Duration("rate-limiter-base-delay", 5*time.Millisecond, "Delay before retrying a failed reconcile, doubled on each failure.")
+	maxDelay := flag.Duration("rate-limiter-max-delay", 1000*time.Second, "Maximum delay before retrying a failed reconcile.")
+	qps := flag.Float64("kube-api-qps", 5, "Maximum number of requests per second to the Kubernetes API server.")
+	burst := flag.Int("kube-api-burst", 10, "Maximum burst of requests to the Kubernetes API server.")
 	flag.Parse()
 
 	// Set up logging.
@@ -73,9 +96,88 @@ func run() int {
 		logger = level.NewFilter(logger, level.AllowInfo())
 	}
 
-	flags := &FlagOpts{
-		Namespace:           *namespace,
-		AssumeCRDRegistered: *assumeCRDRegistered,
+	if isFlagSet("namespace") && isFlagSet("namespace-selector") {
+		level.Error(logger).Log("msg", "--namespace and --namespace-selector can't be combined")
+		return 1
+	}
+
+	if *shardNamespace != "" && *shardIdentity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			level.Error(logger).Log("msg", errors.Wrap(err, "no --shard-identity given, and reading the hostname failed"))
+			return 1
+		}
+		*shardIdentity = hostname
+	}
+
+	// loadFlagOpts reads the config file, if any, and overrides its settings
+	// with the flags which were set.
+	loadFlagOpts := func() (*FlagOpts, error) {
+		flags := &FlagOpts{
+			OperatorConfig:      defaultOperatorConfig(),
+			AssumeCRDRegistered: *assumeCRDRegistered,
+			DryRun:              *dryRun,
+			ShardNamespace:      *shardNamespace,
+			ShardIdentity:       *shardIdentity,
+			ShardLeaseDuration:  *shardLeaseDuration,
+		}
+
+		if *configFile != "" {
+			if err := loadConfig(*configFile, &flags.OperatorConfig); err != nil {
+				return nil, err
+			}
+		}
+
+		flag.Visit(func(f *flag.Flag) {
+			c := &flags.OperatorConfig
+			t := &flags.Tuning
+			switch f.Name {
+			case "namespace":
+				c.Namespaces = nil
+				if ns := parseNamespaces(*namespace); ns[0] != metav1.NamespaceAll {
+					c.Namespaces = ns
+				}
+				c.NamespaceSelector = ""
+			case "namespace-selector":
+				c.NamespaceSelector = *namespaceSelector
+				c.Namespaces = nil
+			case "habitat-selector":
+				c.HabitatSelector = *habitatSelector
+			case "listen-address":
+				c.ListenAddress = *listenAddress
+			case "workers":
+				t.Workers = *workers
+			case "resync-period":
+				t.ResyncPeriod = metav1.Duration{Duration: *resync}
+			case "resync-periods":
+				if t.ResyncPeriods == nil {
+					t.ResyncPeriods = map[string]metav1.Duration{}
+				}
+				for name, d := range resyncPeriods {
+					t.ResyncPeriods[name] = d
+				}
+			case "rate-limiter-base-delay":
+				t.RateLimiter.BaseDelay = metav1.Duration{Duration: *baseDelay}
+			case "rate-limiter-max-delay":
+				t.RateLimiter.MaxDelay = metav1.Duration{Duration: *maxDelay}
+			case "kube-api-qps":
+				t.KubeAPI.QPS = float32(*qps)
+			case "kube-api-burst":
+				t.KubeAPI.Burst = *burst
+			}
+		})
+
+		if err := flags.validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid config")
+		}
+
+		return flags, nil
+	}
+
+	flags, err := loadFlagOpts()
+	if err != nil {
+		level.Error(logger).Log("msg", err)
+		return 1
 	}
 
 	// Build operator config.
@@ -84,6 +186,8 @@ func run() int {
 		level.Error(logger).Log("msg", err)
 		return 1
 	}
+	config.QPS = flags.Tuning.KubeAPI.QPS
+	config.Burst = flags.Tuning.KubeAPI.Burst
 
 	// This is the clientset for interacting with the apiextensions group.
 	apiextensionsClientset, err := apiextensionsclient.NewForConfig(config)
@@ -109,7 +213,7 @@ func run() int {
 	// check if the operator has right permissions when trying to run cluster wide
 	// here since namespace is not provided so we are looking at all the namespaces
 	// Operator should have permission to query all the namespaces
-	if flags.Namespace == metav1.NamespaceAll {
+	if len(flags.Namespaces) == 0 && flags.NamespaceSelector == "" {
 		level.Info(logger).Log("msg", "Running operator at cluster scope, looking for all the namespaces")
 		if _, err := kubeClientset.CoreV1().Namespaces().List(metav1.ListOptions{}); err != nil {
 			level.Error(logger).Log("msg", errors.Wrap(err, "Operator does not have cluster wide permissions"))
@@ -120,20 +224,12 @@ func run() int {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
 	cSets := Clientsets{
 		KubeClientset:          kubeClientset,
 		HabClientset:           habClientset,
 		ApiextensionsClientset: apiextensionsClientset,
 	}
 
-	if err := v1beta2(ctx, &wg, cSets, logger, flags); err != nil {
-		level.Error(logger).Log("msg", err)
-		return 1
-	}
-
 	term := make(chan os.Signal, 2)
 	// Relay these signals to the `term` channel.
 	signal.Notify(term, syscall.SIGINT, syscall.SIGTERM)
@@ -147,14 +243,109 @@ func run() int {
 		os.Exit(1)
 	}()
 
-	<-ctx.Done()
+	// failed receives the error of a controller which stopped on its own.
+	failed := make(chan error, 1)
+	exitCode := 0
 
-	// Block until the WaitGroup counter is zero
-	wg.Wait()
+	reloads := make(chan *FlagOpts)
+	if *configFile != "" {
+		watchConfig(ctx, *configFile, configPollInterval, loadFlagOpts, reloads, logger)
+	}
+
+	// The controllers are restarted whenever the namespaces matching the
+	// namespace selector change, or settings of the config file which can't
+	// be changed while they run.
+	for {
+		runCtx, cancelRun := context.WithCancel(ctx)
+
+		namespaces := flags.watchedNamespaces()
+		if flags.NamespaceSelector != "" {
+			namespaces, err = selectedNamespaces(kubeClientset, flags.NamespaceSelector)
+			if err != nil {
+				cancelRun()
+				level.Error(logger).Log("msg", errors.Wrap(err, "listing the namespaces matching the selector failed"))
+				return 1
+			}
+
+			watchNamespaces(runCtx, kubeClientset, flags.NamespaceSelector, namespaces, flags.Tuning.resyncPeriod("namespaces"), cancelRun, logger)
+		}
+
+		var wg sync.WaitGroup
+		var controller *habv1beta2controller.HabitatController
+
+		if len(namespaces) == 0 {
+			level.Info(logger).Log("msg", "no namespaces match the selector, waiting", "selector", flags.NamespaceSelector)
+		} else {
+			wg.Add(1)
+
+			controller, err = v1beta2(runCtx, &wg, cSets, logger, flags, namespaces, failed)
+			if err != nil {
+				cancelRun()
+				level.Error(logger).Log("msg", err)
+				return 1
+			}
+		}
+
+	running:
+		for {
+			select {
+			case <-runCtx.Done():
+				break running
+			case err := <-failed:
+				level.Error(logger).Log("msg", "controller failed", "err", err)
+				exitCode = 1
+				cancelFunc()
+			case newFlags := <-reloads:
+				if newFlags.Tuning.KubeAPI != flags.Tuning.KubeAPI {
+					level.Warn(logger).Log("msg", "the Kubernetes API rate limit only changes when the operator is restarted")
+					newFlags.Tuning.KubeAPI = flags.Tuning.KubeAPI
+				}
+
+				if controller != nil && liveSettingsChanged(flags.OperatorConfig, newFlags.OperatorConfig) {
+					controller.SetHabitatDefaults(newFlags.HabitatDefaults)
+					controller.SetFeatureGates(newFlags.FeatureGates)
+					level.Info(logger).Log("msg", "applied new Habitat defaults and feature gates")
+				}
+
+				restart := restartNeeded(flags.OperatorConfig, newFlags.OperatorConfig)
+				flags = newFlags
+				if restart {
+					cancelRun()
+				}
+			}
+		}
+
+		// Block until the WaitGroup counter is zero
+		wg.Wait()
+		cancelRun()
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		level.Info(logger).Log("msg", "restarting controllers")
+	}
 
 	level.Info(logger).Log("msg", "controllers stopped, exiting")
 
-	return 0
+	return exitCode
+}
+
+// isFlagSet returns whether the flag name was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// informerFactory is implemented by the informer factories of all API groups.
+type informerFactory interface {
+	Start(stopCh <-chan struct{})
 }
 
 // createCRD creates Habitat CRD in the cluster, provided the operator has 'create'
@@ -172,62 +363,135 @@ func createCRD(cSets Clientsets, logger log.Logger) error {
 	return nil
 }
 
-func v1beta2(ctx context.Context, wg *sync.WaitGroup, cSets Clientsets, logger log.Logger, flags *FlagOpts) error {
+// v1beta2 runs the v1beta2 controller, watching namespaces, until ctx is done.
+// A single metav1.NamespaceAll stands for all namespaces. If the controller
+// fails before, its error is sent to failed.
+func v1beta2(ctx context.Context, wg *sync.WaitGroup, cSets Clientsets, logger log.Logger, flags *FlagOpts, namespaces []string, failed chan<- error) (*habv1beta2controller.HabitatController, error) {
 	// if user has already created CRD in the cluster with help of cluster-admin
-	// then operator does not need to create CRD
-	if !flags.AssumeCRDRegistered {
+	// then operator does not need to create CRD. Neither does it in a dry run,
+	// which doesn't write anything.
+	if !flags.AssumeCRDRegistered && !flags.DryRun {
 		if err := createCRD(cSets, logger); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(
-		cSets.KubeClientset,
-		resyncPeriod,
-		kubeinformers.WithNamespace(flags.Namespace),
-	)
-	habInformerFactory := habinformers.NewSharedInformerFactoryWithOptions(
-		cSets.HabClientset,
-		resyncPeriod,
-		habinformers.WithNamespace(flags.Namespace),
-	)
+	var factories []informerFactory
+	newFactories := func(namespace string) habv1beta2controller.NamespaceInformerFactories {
+		kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(
+			cSets.KubeClientset,
+			flags.Tuning.ResyncPeriod.Duration,
+			kubeinformers.WithNamespace(namespace),
+			kubeinformers.WithCustomResyncConfig(flags.Tuning.customResyncConfig()),
+		)
+		habInformerFactory := habinformers.NewSharedInformerFactoryWithOptions(
+			cSets.HabClientset,
+			flags.Tuning.ResyncPeriod.Duration,
+			habinformers.WithNamespace(namespace),
+			habinformers.WithCustomResyncConfig(flags.Tuning.customResyncConfig()),
+			habinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = flags.HabitatSelector
+			}),
+		)
+		factories = append(factories, kubeInformerFactory, habInformerFactory)
+
+		return habv1beta2controller.NamespaceInformerFactories{
+			KubeInformerFactory:    kubeInformerFactory,
+			HabitatInformerFactory: habInformerFactory,
+		}
+	}
 
 	config := habv1beta2controller.Config{
 		// NOTE: The v1beta2 controller still needs to use a v1beta1 client,
 		// because we _have_ only one client.  This is due to the fact that it's
 		// not currently possible to have multiple versions of a CRD (and
 		// therefore, of a client), running at the same time
-		HabitatClient:          cSets.HabClientset.HabitatV1beta1().RESTClient(),
-		KubernetesClientset:    cSets.KubeClientset,
-		KubeInformerFactory:    kubeInformerFactory,
-		HabitatInformerFactory: habInformerFactory,
-		Namespace:              flags.Namespace,
+		HabitatClient:       cSets.HabClientset.HabitatV1beta1().RESTClient(),
+		KubernetesClientset: cSets.KubeClientset,
+		DryRun:              flags.DryRun,
+		RateLimiter:         flags.Tuning.rateLimiter(),
+		HabitatDefaults:     flags.HabitatDefaults,
+		FeatureGates:        flags.FeatureGates,
 	}
+
+	if flags.ShardNamespace != "" {
+		config.Sharding = &habv1beta2controller.ShardingConfig{
+			Namespace:     flags.ShardNamespace,
+			Identity:      flags.ShardIdentity,
+			LeaseDuration: flags.ShardLeaseDuration,
+		}
+	}
+
+	if len(namespaces) == 1 {
+		f := newFactories(namespaces[0])
+		config.KubeInformerFactory = f.KubeInformerFactory
+		config.HabitatInformerFactory = f.HabitatInformerFactory
+		config.Namespace = namespaces[0]
+	} else {
+		config.Namespaces = map[string]habv1beta2controller.NamespaceInformerFactories{}
+		for _, ns := range namespaces {
+			config.Namespaces[ns] = newFactories(ns)
+		}
+	}
+
+	level.Info(logger).Log("msg", "watching namespaces", "namespaces", strings.Join(namespaces, ","), "habitat-selector", flags.HabitatSelector)
 	controller, err := habv1beta2controller.New(config, log.With(logger, "component", "controller/v1beta2"))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if flags.ListenAddress != "" {
+		serveHTTP(ctx, wg, flags.ListenAddress, controller, logger)
 	}
 
 	var factoriesWg sync.WaitGroup
-	factoriesWg.Add(2)
+	factoriesWg.Add(len(factories))
+
+	for _, f := range factories {
+		go func(f informerFactory) {
+			f.Start(ctx.Done())
+			factoriesWg.Done()
+		}(f)
+	}
 
 	go func() {
-		kubeInformerFactory.Start(ctx.Done())
-		factoriesWg.Done()
+		if err := controller.Run(ctx, flags.Tuning.Workers); err != nil && ctx.Err() == nil {
+			select {
+			case failed <- err:
+			default:
+			}
+		}
+		factoriesWg.Wait()
+		wg.Done()
 	}()
 
+	return controller, nil
+}
+
+// serveHTTP serves the operator's HTTP endpoints on addr until ctx is done,
+// and marks wg done once the server is closed.
+func serveHTTP(ctx context.Context, wg *sync.WaitGroup, addr string, controller *habv1beta2controller.HabitatController, logger log.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/binds", controller.BindGraphHandler())
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
 	go func() {
-		habInformerFactory.Start(ctx.Done())
-		factoriesWg.Done()
+		level.Info(logger).Log("msg", "serving HTTP endpoints", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			level.Error(logger).Log("msg", "HTTP server failed", "err", err)
+		}
 	}()
 
+	wg.Add(1)
 	go func() {
-		controller.Run(ctx, runtime.NumCPU())
-		factoriesWg.Wait()
+		<-ctx.Done()
+		server.Close()
 		wg.Done()
 	}()
-
-	return nil
 }
 
 func printVersion() {
@@ -236,6 +500,10 @@ func printVersion() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	printVersion()
 	os.Exit(run())
 }
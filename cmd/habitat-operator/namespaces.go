@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// parseNamespaces splits the comma-separated list of namespaces s. An empty
+// list stands for all namespaces.
+func parseNamespaces(s string) []string {
+	seen := map[string]bool{}
+	namespaces := []string{}
+
+	for _, ns := range strings.Split(s, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+
+	if len(namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// selectedNamespaces returns the sorted names of the namespaces matching the
+// label selector.
+func selectedNamespaces(clientset kubernetes.Interface, selector string) ([]string, error) {
+	list, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// watchNamespaces calls changed once the namespaces matching the label
+// selector differ from namespaces, until ctx is done. Changes made before the
+// watch started are noticed on its next resync.
+func watchNamespaces(ctx context.Context, clientset kubernetes.Interface, selector string, namespaces []string, resyncPeriod time.Duration, changed func(), logger log.Logger) {
+	source := cache.NewFilteredListWatchFromClient(
+		clientset.CoreV1().RESTClient(),
+		"namespaces",
+		metav1.NamespaceAll,
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		})
+
+	informer := cache.NewSharedIndexInformer(source, &apiv1.Namespace{}, resyncPeriod, cache.Indexers{})
+
+	check := func() {
+		if !informer.HasSynced() {
+			return
+		}
+
+		current := informer.GetStore().ListKeys()
+		sort.Strings(current)
+
+		if !reflect.DeepEqual(current, namespaces) {
+			level.Info(logger).Log("msg", "namespaces matching selector changed", "selector", selector, "namespaces", strings.Join(current, ","))
+			changed()
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { check() },
+		UpdateFunc: func(interface{}, interface{}) { check() },
+		DeleteFunc: func(interface{}) { check() },
+	})
+
+	go informer.Run(ctx.Done())
+}
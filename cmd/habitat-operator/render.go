@@ -0,0 +1,188 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	habscheme "github.com/habitat-sh/habitat-operator/pkg/client/clientset/versioned/scheme"
+	habv1beta2controller "github.com/habitat-sh/habitat-operator/pkg/controller/v1beta2"
+)
+
+// manifestFiles holds the values of a repeatable flag.
+type manifestFiles []string
+
+func (m *manifestFiles) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *manifestFiles) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// manifests holds the objects read from manifest files.
+type manifests struct {
+	habitats   []*habv1beta1.Habitat
+	secrets    []*apiv1.Secret
+	configMaps []*apiv1.ConfigMap
+}
+
+// render prints the objects the operator would create for the Habitats in the
+// manifest files passed in args, without connecting to a cluster.
+func render(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var files manifestFiles
+	fs.Var(&files, "f", "Manifest file containing Habitats, and the Secrets and ConfigMaps they reference. Can be repeated. \"-\" reads from standard input.")
+	namespace := fs.String("namespace", metav1.NamespaceDefault, "Namespace of the objects which don't specify one.")
+	configFile := fs.String("config", "", "Path to the YAML config file of the operator, whose Habitat defaults and feature gates are applied.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config := defaultOperatorConfig()
+	if *configFile != "" {
+		if err := loadConfig(*configFile, &config); err != nil {
+			fmt.Fprintf(stderr, "render: %v\n", err)
+			return 1
+		}
+		if err := config.validate(); err != nil {
+			fmt.Fprintf(stderr, "render: invalid config: %v\n", err)
+			return 1
+		}
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintln(stderr, "render: at least one manifest file must be passed with -f")
+		return 2
+	}
+
+	habscheme.AddToScheme(scheme.Scheme)
+
+	m := &manifests{}
+	for _, f := range files {
+		if err := m.readFile(f, stdin, *namespace, stderr); err != nil {
+			fmt.Fprintf(stderr, "render: %s: %v\n", f, err)
+			return 1
+		}
+	}
+
+	objects, err := habv1beta2controller.Render(m.habitats, m.secrets, m.configMaps, config.HabitatDefaults, config.FeatureGates)
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			fmt.Fprintf(stderr, "render: %v\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(stdout, "---\n%s", data)
+	}
+
+	return 0
+}
+
+// readFile reads the objects in the manifest file name, or in stdin if name is
+// "-". Objects without a namespace are put in namespace.
+func (m *manifests) readFile(name string, stdin io.Reader, namespace string, stderr io.Writer) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		obj, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		if !m.add(obj, namespace) {
+			fmt.Fprintf(stderr, "render: %s: ignoring %s\n", name, gvk.Kind)
+		}
+	}
+}
+
+// add adds obj to m, and returns true, if it is a Habitat or an object
+// Habitats can reference.
+func (m *manifests) add(obj runtime.Object, namespace string) bool {
+	switch o := obj.(type) {
+	case *habv1beta1.Habitat:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		m.habitats = append(m.habitats, o)
+	case *apiv1.Secret:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		// The API server converts stringData, so it has to be done here.
+		if len(o.StringData) > 0 && o.Data == nil {
+			o.Data = map[string][]byte{}
+		}
+		for k, v := range o.StringData {
+			o.Data[k] = []byte(v)
+		}
+		o.StringData = nil
+		m.secrets = append(m.secrets, o)
+	case *apiv1.ConfigMap:
+		if o.Namespace == "" {
+			o.Namespace = namespace
+		}
+		m.configMaps = append(m.configMaps, o)
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Tuning holds the settings adapting the operator to the size of the cluster.
+// They can be set in the config file, and overridden by flags.
+type Tuning struct {
+	// Workers is the number of Habitats reconciled concurrently.
+	Workers int `json:"workers,omitempty"`
+	// ResyncPeriod is how often the informers resync their cache.
+	ResyncPeriod metav1.Duration `json:"resyncPeriod,omitempty"`
+	// ResyncPeriods overrides ResyncPeriod for the informers of some
+	// resources, e.g. "pods".
+	ResyncPeriods map[string]metav1.Duration `json:"resyncPeriods,omitempty"`
+	// RateLimiter delays the retries of failed reconciles.
+	RateLimiter RateLimiterTuning `json:"rateLimiter,omitempty"`
+	// KubeAPI limits the requests sent to the Kubernetes API server.
+	KubeAPI KubeAPITuning `json:"kubeAPI,omitempty"`
+}
+
+// RateLimiterTuning sets the exponential backoff of failed reconciles.
+type RateLimiterTuning struct {
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+	MaxDelay  metav1.Duration `json:"maxDelay,omitempty"`
+}
+
+// KubeAPITuning sets the client side rate limit of the requests to the
+// Kubernetes API server.
+type KubeAPITuning struct {
+	QPS   float32 `json:"qps,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+// resyncObjects maps the resources whose resync period can be set to the
+// objects their informers are keyed by.
+var resyncObjects = map[string]metav1.Object{
+	"habitats":             &habv1beta1.Habitat{},
+	"statefulsets":         &appsv1.StatefulSet{},
+	"configmaps":           &apiv1.ConfigMap{},
+	"secrets":              &apiv1.Secret{},
+	"networkpolicies":      &networkingv1.NetworkPolicy{},
+	"poddisruptionbudgets": &policyv1beta1.PodDisruptionBudget{},
+	"pods":                 &apiv1.Pod{},
+	"namespaces":           &apiv1.Namespace{},
+}
+
+func defaultTuning() Tuning {
+	return Tuning{
+		Workers:      runtime.NumCPU(),
+		ResyncPeriod: metav1.Duration{Duration: 30 * time.Second},
+		ResyncPeriods: map[string]metav1.Duration{
+			"pods": {Duration: time.Minute},
+		},
+		RateLimiter: RateLimiterTuning{
+			BaseDelay: metav1.Duration{Duration: 5 * time.Millisecond},
+			MaxDelay:  metav1.Duration{Duration: 1000 * time.Second},
+		},
+		KubeAPI: KubeAPITuning{
+			QPS:   5,
+			Burst: 10,
+		},
+	}
+}
+
+func (t Tuning) validate() error {
+	if t.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1, got %d", t.Workers)
+	}
+	if t.ResyncPeriod.Duration < 0 {
+		return fmt.Errorf("resync period can't be negative, got %s", t.ResyncPeriod.Duration)
+	}
+	for name, d := range t.ResyncPeriods {
+		if _, ok := resyncObjects[name]; !ok {
+			return fmt.Errorf("unknown resource %q in resync periods, expected one of %s", name, strings.Join(resyncNames(), ", "))
+		}
+		if d.Duration < 0 {
+			return fmt.Errorf("resync period of %s can't be negative, got %s", name, d.Duration)
+		}
+	}
+	if t.RateLimiter.BaseDelay.Duration <= 0 || t.RateLimiter.MaxDelay.Duration < t.RateLimiter.BaseDelay.Duration {
+		return fmt.Errorf("rate limiter delays must be positive, and the max delay at least the base delay, got %s and %s", t.RateLimiter.BaseDelay.Duration, t.RateLimiter.MaxDelay.Duration)
+	}
+	if t.KubeAPI.QPS <= 0 || t.KubeAPI.Burst < 1 {
+		return fmt.Errorf("Kubernetes API QPS and burst must be positive, got %v and %d", t.KubeAPI.QPS, t.KubeAPI.Burst)
+	}
+
+	return nil
+}
+
+func resyncNames() []string {
+	var names []string
+	for name := range resyncObjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// resyncPeriod returns the resync period of the informer of resource.
+func (t Tuning) resyncPeriod(resource string) time.Duration {
+	if d, ok := t.ResyncPeriods[resource]; ok {
+		return d.Duration
+	}
+
+	return t.ResyncPeriod.Duration
+}
+
+// customResyncConfig returns the resync periods overriding the default one,
+// in the form taken by informer factories.
+func (t Tuning) customResyncConfig() map[metav1.Object]time.Duration {
+	config := map[metav1.Object]time.Duration{}
+	for name, d := range t.ResyncPeriods {
+		config[resyncObjects[name]] = d.Duration
+	}
+
+	return config
+}
+
+// rateLimiter returns the rate limiter of the work queue. Like the default
+// one of client-go, it also limits all retries to 10 per second, with bursts
+// of 100.
+func (t Tuning) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(t.RateLimiter.BaseDelay.Duration, t.RateLimiter.MaxDelay.Duration),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
+// resyncPeriodsFlag parses a comma-separated list of resource=duration pairs,
+// e.g. "pods=1m,secrets=5m".
+type resyncPeriodsFlag map[string]metav1.Duration
+
+func (f resyncPeriodsFlag) String() string {
+	var pairs []string
+	for name, d := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, d.Duration))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (f resyncPeriodsFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected resource=duration, got %q", pair)
+		}
+
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return err
+		}
+
+		f[strings.TrimSpace(parts[0])] = metav1.Duration{Duration: d}
+	}
+
+	return nil
+}
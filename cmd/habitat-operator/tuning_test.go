@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadTuning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	config := `
+workers: 16
+resyncPeriods:
+  secrets: 5m
+kubeAPI:
+  qps: 50
+`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file has no apiVersion, like those written before the config file
+	// was versioned.
+	c := defaultOperatorConfig()
+	if err := loadConfig(path, &c); err != nil {
+		t.Fatal(err)
+	}
+	tuning := c.Tuning
+	if err := tuning.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tuning.Workers != 16 {
+		t.Errorf("workers = %d, want 16", tuning.Workers)
+	}
+	if got := tuning.resyncPeriod("secrets"); got != 5*time.Minute {
+		t.Errorf("secrets resync period = %s, want 5m", got)
+	}
+	// Settings missing from the file keep their default.
+	if got := tuning.resyncPeriod("pods"); got != time.Minute {
+		t.Errorf("pods resync period = %s, want 1m", got)
+	}
+	if got := tuning.resyncPeriod("habitats"); got != 30*time.Second {
+		t.Errorf("habitats resync period = %s, want 30s", got)
+	}
+	if tuning.KubeAPI.QPS != 50 || tuning.KubeAPI.Burst != 10 {
+		t.Errorf("Kubernetes API QPS and burst = %v, %d, want 50, 10", tuning.KubeAPI.QPS, tuning.KubeAPI.Burst)
+	}
+}
+
+func TestTuningValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Tuning)
+	}{
+		{"no workers", func(tu *Tuning) { tu.Workers = 0 }},
+		{"unknown resource", func(tu *Tuning) { resyncPeriodsFlag(tu.ResyncPeriods).Set("deployments=1m") }},
+		{"max delay below base delay", func(tu *Tuning) { tu.RateLimiter.MaxDelay.Duration = time.Millisecond }},
+		{"no burst", func(tu *Tuning) { tu.KubeAPI.Burst = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tuning := defaultTuning()
+			tt.modify(&tuning)
+
+			if err := tuning.validate(); err == nil {
+				t.Error("validate() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+// defaultHTTPPort is the port of the supervisor's HTTP gateway, unless the
+// service container declares an `http` port.
+const defaultHTTPPort = 9631
+
+// census is the part of the census of a supervisor, as served by its HTTP
+// gateway, used by the commands.
+type census struct {
+	CensusGroups map[string]censusGroup `json:"census_groups"`
+}
+
+type censusGroup struct {
+	Population map[string]censusMember `json:"population"`
+}
+
+type censusMember struct {
+	MemberID  string `json:"member_id"`
+	Leader    bool   `json:"leader"`
+	Follower  bool   `json:"follower"`
+	Alive     bool   `json:"alive"`
+	Suspect   bool   `json:"suspect"`
+	Confirmed bool   `json:"confirmed"`
+	Departed  bool   `json:"departed"`
+	Sys       struct {
+		Hostname string `json:"hostname"`
+		IP       string `json:"ip"`
+	} `json:"sys"`
+}
+
+// health returns the health of the member, as seen by the supervisor which
+// served the census.
+func (m censusMember) health() string {
+	switch {
+	case m.Departed:
+		return "departed"
+	case m.Confirmed:
+		return "confirmed dead"
+	case m.Suspect:
+		return "suspect"
+	default:
+		return "alive"
+	}
+}
+
+// role returns the role of the member in its service group's election.
+func (m censusMember) role() string {
+	switch {
+	case m.Leader:
+		return "leader"
+	case m.Follower:
+		return "follower"
+	default:
+		return "-"
+	}
+}
+
+// serviceGroup returns the name of the service group of the Habitat h, as used
+// in the census.
+func serviceGroup(h *habv1beta1.Habitat) string {
+	group := "default"
+	if g := h.Spec.V1beta2.Service.Group; g != nil {
+		group = *g
+	}
+
+	return fmt.Sprintf("%s.%s", h.Spec.V1beta2.Service.Name, group)
+}
+
+// members returns the members of the service group of the Habitat h in c,
+// sorted by hostname.
+func (c *census) members(h *habv1beta1.Habitat) []censusMember {
+	var members []censusMember
+	for name, g := range c.CensusGroups {
+		// Groups of other organizations have a `@org` suffix.
+		if name != serviceGroup(h) && !strings.HasPrefix(name, serviceGroup(h)+"@") {
+			continue
+		}
+
+		for _, m := range g.Population {
+			members = append(members, m)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Sys.Hostname < members[j].Sys.Hostname
+	})
+
+	return members
+}
+
+// leader returns the hostname of the leader of the service group of the
+// Habitat h in c, or an empty string if there is none.
+func (c *census) leader(h *habv1beta1.Habitat) string {
+	for _, m := range c.members(h) {
+		if m.Leader {
+			return m.Sys.Hostname
+		}
+	}
+
+	return ""
+}
+
+// habitatPods returns the Pods of the Habitat h.
+func (c *cli) habitatPods(h *habv1beta1.Habitat) ([]apiv1.Pod, error) {
+	selector := labels.SelectorFromSet(labels.Set{habv1beta1.HabitatNameLabel: h.Name})
+
+	pods, err := c.kube.CoreV1().Pods(h.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	return pods.Items, nil
+}
+
+// httpPort returns the port of the HTTP gateway of the supervisor in pod.
+func httpPort(pod apiv1.Pod) int32 {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == "http" {
+				return p.ContainerPort
+			}
+		}
+	}
+
+	return defaultHTTPPort
+}
+
+func podReady(pod apiv1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// census returns the census of the supervisor of the first ready Pod of the
+// Habitat h, through the API server's Pod proxy.
+func (c *cli) census(h *habv1beta1.Habitat) (*census, error) {
+	pods, err := c.habitatPods(h)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range pods {
+		if !podReady(pod) {
+			continue
+		}
+
+		data, err := c.kube.CoreV1().RESTClient().Get().
+			Namespace(pod.Namespace).
+			Resource("pods").
+			Name(fmt.Sprintf("%s:%d", pod.Name, httpPort(pod))).
+			SubResource("proxy").
+			Suffix("census").
+			DoRaw()
+		if err != nil {
+			return nil, err
+		}
+
+		var cen census
+		if err := json.Unmarshal(data, &cen); err != nil {
+			return nil, fmt.Errorf("could not parse the census of Pod %s: %v", pod.Name, err)
+		}
+
+		return &cen, nil
+	}
+
+	return nil, fmt.Errorf("Habitat %s has no ready Pods", h.Name)
+}
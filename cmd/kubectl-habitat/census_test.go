@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+func newTestHabitat(service string, group *string) *habv1beta1.Habitat {
+	return &habv1beta1.Habitat{
+		Spec: habv1beta1.HabitatSpec{
+			V1beta2: &habv1beta1.V1beta2{
+				Count: 3,
+				Service: habv1beta1.ServiceV1beta2{
+					Name:  service,
+					Group: group,
+				},
+			},
+		},
+	}
+}
+
+func TestCensusLeader(t *testing.T) {
+	data := `{
+  "census_groups": {
+    "redis.default": {
+      "population": {
+        "a": {"member_id": "a", "follower": true, "sys": {"hostname": "redis-1", "ip": "10.0.0.2"}},
+        "b": {"member_id": "b", "leader": true, "sys": {"hostname": "redis-0", "ip": "10.0.0.1"}}
+      }
+    },
+    "nginx.default": {
+      "population": {
+        "c": {"member_id": "c", "leader": true, "sys": {"hostname": "nginx-0", "ip": "10.0.0.3"}}
+      }
+    }
+  }
+}`
+
+	var cen census
+	if err := json.Unmarshal([]byte(data), &cen); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHabitat("redis", nil)
+
+	members := cen.members(h)
+	if len(members) != 2 || members[0].Sys.Hostname != "redis-0" || members[1].role() != "follower" {
+		t.Errorf("members() = %+v, want redis-0 and the follower redis-1", members)
+	}
+	if got := cen.leader(h); got != "redis-0" {
+		t.Errorf("leader() = %q, want redis-0", got)
+	}
+
+	other := "other"
+	if got := cen.leader(newTestHabitat("redis", &other)); got != "" {
+		t.Errorf("leader() of another group = %q, want none", got)
+	}
+}
+
+func TestHabitatStatus(t *testing.T) {
+	h := newTestHabitat("redis", nil)
+
+	if got := habitatStatus(h, 1); got != "Progressing" {
+		t.Errorf("habitatStatus() = %q, want Progressing", got)
+	}
+	if got := habitatStatus(h, 3); got != "Ready" {
+		t.Errorf("habitatStatus() = %q, want Ready", got)
+	}
+
+	h.Status.Conditions = []habv1beta1.HabitatCondition{
+		{Type: habv1beta1.HabitatConditionBindsResolved, Status: apiv1.ConditionFalse, Reason: "UnresolvedBinds"},
+	}
+	if got := habitatStatus(h, 3); got != "UnresolvedBinds" {
+		t.Errorf("habitatStatus() = %q, want UnresolvedBinds", got)
+	}
+}
+
+func TestNewRingKey(t *testing.T) {
+	key, err := newRingKey("foobar-20180101000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(key), "\n")
+	if len(lines) != 4 || lines[0] != "SYM-SEC-1" || lines[1] != "foobar-20180101000000" || lines[2] != "" || len(lines[3]) != 44 {
+		t.Errorf("newRingKey() = %q, want a SYM-SEC-1 key for revision foobar-20180101000000", key)
+	}
+}
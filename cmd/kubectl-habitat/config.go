@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/BurntSushi/toml"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// config manages the config of a Habitat's service. Its only subcommand,
+// apply, sets the inline TOML config of the service, which the operator
+// renders and mounts in its Pods.
+func config(c *cli, args []string) error {
+	name, file, err := parseConfigArgs(args)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	// Catch syntax errors before the operator does.
+	var parsed map[string]interface{}
+	if _, err := toml.Decode(string(data), &parsed); err != nil {
+		return fmt.Errorf("invalid config %s: %v", file, err)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"v1beta2": map[string]interface{}{
+				"service": map[string]interface{}{
+					"config": map[string]string{
+						"toml": string(data),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.hab.HabitatV1beta1().Habitats(c.namespace).Patch(name, types.MergePatchType, patch); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "habitat %q configured\n", name)
+
+	return nil
+}
+
+// parseConfigArgs returns the name of the Habitat and the config file passed
+// to config apply.
+func parseConfigArgs(args []string) (string, string, error) {
+	fs := flag.NewFlagSet("config apply", flag.ContinueOnError)
+	file := fs.String("f", "", "File containing the config, in TOML format.")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(positional) == 0 || positional[0] != "apply" {
+		return "", "", fmt.Errorf("expected the apply subcommand")
+	}
+	if len(positional) != 2 || *file == "" {
+		return "", "", fmt.Errorf("expected the name of a Habitat and a config file")
+	}
+
+	return positional[1], *file, nil
+}
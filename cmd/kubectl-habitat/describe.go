@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+// describe prints the spec, binds, events and supervisor census of a Habitat.
+func describe(c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a Habitat")
+	}
+
+	h, err := c.hab.HabitatV1beta1().Habitats(c.namespace).Get(args[0], metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if h.Spec.V1beta2 == nil {
+		return fmt.Errorf("Habitat %s has no v1beta2 spec", h.Name)
+	}
+
+	ready, err := c.readyReplicas(h)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Name:\t%s\n", h.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", h.Namespace)
+	fmt.Fprintf(w, "Service:\t%s\n", serviceGroup(h))
+	fmt.Fprintf(w, "Topology:\t%s\n", h.Spec.V1beta2.Service.Topology)
+	fmt.Fprintf(w, "Ready:\t%d/%d\n", ready, h.Spec.V1beta2.Count)
+	fmt.Fprintf(w, "Status:\t%s\n", habitatStatus(h, ready))
+
+	spec, err := yaml.Marshal(h.Spec.V1beta2)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Spec:\n%s", indent(string(spec)))
+
+	if len(h.Status.Conditions) > 0 {
+		fmt.Fprintln(w, "Conditions:")
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, cond := range h.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+		}
+	}
+
+	if binds := h.Spec.V1beta2.Service.Bind; len(binds) > 0 {
+		fmt.Fprintln(w, "Binds:")
+		fmt.Fprintln(w, "  NAME\tSERVICE\tPROVIDERS")
+		for _, b := range binds {
+			providers, err := c.bindProviders(h, b)
+			if err != nil {
+				return err
+			}
+
+			p := "<none>"
+			if len(providers) > 0 {
+				p = strings.Join(providers, ", ")
+			}
+			fmt.Fprintf(w, "  %s\t%s.%s\t%s\n", b.Name, b.Service, b.Group, p)
+		}
+	}
+
+	if ready > 0 {
+		fmt.Fprintln(w, "Census:")
+		cen, err := c.census(h)
+		if err != nil {
+			fmt.Fprintf(w, "  <unavailable: %v>\n", err)
+		} else {
+			fmt.Fprintln(w, "  HOSTNAME\tIP\tROLE\tHEALTH")
+			for _, m := range cen.members(h) {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", m.Sys.Hostname, m.Sys.IP, m.role(), m.health())
+			}
+		}
+	}
+
+	selector := fields.SelectorFromSet(fields.Set{
+		"involvedObject.kind": habv1beta1.HabitatKind,
+		"involvedObject.name": h.Name,
+	})
+	events, err := c.kube.CoreV1().Events(h.Namespace).List(metav1.ListOptions{FieldSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Events:")
+	if len(events.Items) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return nil
+	}
+
+	sort.Slice(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+
+	fmt.Fprintln(w, "  LAST SEEN\tTYPE\tREASON\tMESSAGE")
+	for _, e := range events.Items {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", e.LastTimestamp.Format("2006-01-02 15:04:05"), e.Type, e.Reason, e.Message)
+	}
+
+	return nil
+}
+
+// bindProviders returns the names of the Habitats providing the service the
+// bind b of the Habitat h refers to.
+func (c *cli) bindProviders(h *habv1beta1.Habitat, b habv1beta1.Bind) ([]string, error) {
+	habitats, err := c.hab.HabitatV1beta1().Habitats(h.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []string
+	for i := range habitats.Items {
+		p := &habitats.Items[i]
+		if p.Spec.V1beta2 != nil && serviceGroup(p) == fmt.Sprintf("%s.%s", b.Service, b.Group) {
+			providers = append(providers, fmt.Sprintf("%s/%s", p.Namespace, p.Name))
+		}
+	}
+
+	return providers, nil
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "  " + l
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
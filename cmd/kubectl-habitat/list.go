@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+// list prints the Habitats, with their status, topology and leader.
+func list(c *cli, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	allNamespaces := fs.Bool("all-namespaces", false, "List the Habitats in all namespaces.")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	namespace := c.namespace
+	if *allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	habitats, err := c.hab.HabitatV1beta1().Habitats(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	if *allNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tSERVICE\tTOPOLOGY\tREADY\tLEADER\tSTATUS")
+
+	for i := range habitats.Items {
+		h := &habitats.Items[i]
+		// Habitats of other versions aren't managed by this operator.
+		if h.Spec.V1beta2 == nil {
+			continue
+		}
+
+		ready, err := c.readyReplicas(h)
+		if err != nil {
+			return err
+		}
+
+		leader := "-"
+		if h.Spec.V1beta2.Service.Topology == habv1beta1.TopologyLeader && ready > 0 {
+			leader = "<unknown>"
+			if cen, err := c.census(h); err == nil {
+				if l := cen.leader(h); l != "" {
+					leader = l
+				}
+			}
+		}
+
+		if *allNamespaces {
+			fmt.Fprintf(w, "%s\t", h.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			h.Name, serviceGroup(h), h.Spec.V1beta2.Service.Topology, ready, h.Spec.V1beta2.Count, leader, habitatStatus(h, ready))
+	}
+
+	return w.Flush()
+}
+
+// readyReplicas returns the number of ready Pods of the Habitat h.
+func (c *cli) readyReplicas(h *habv1beta1.Habitat) (int, error) {
+	sts, err := c.kube.AppsV1().StatefulSets(h.Namespace).Get(h.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return int(sts.Status.ReadyReplicas), nil
+}
+
+// habitatStatus summarizes the status of the Habitat h, which has ready Pods
+// ready.
+func habitatStatus(h *habv1beta1.Habitat, ready int) string {
+	for _, c := range h.Status.Conditions {
+		switch c.Type {
+		case habv1beta1.HabitatConditionPaused:
+			if c.Status == apiv1.ConditionTrue {
+				return "Paused"
+			}
+		case habv1beta1.HabitatConditionBindCycle, habv1beta1.HabitatConditionImmutableFieldsChanged:
+			if c.Status == apiv1.ConditionTrue {
+				return c.Reason
+			}
+		case habv1beta1.HabitatConditionBindsResolved, habv1beta1.HabitatConditionBindsReady, habv1beta1.HabitatConditionVolumeExpansion:
+			if c.Status == apiv1.ConditionFalse {
+				return c.Reason
+			}
+		}
+	}
+
+	if ready < h.Spec.V1beta2.Count {
+		return "Progressing"
+	}
+
+	return "Ready"
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceContainer is the name of the container running the supervisor.
+const serviceContainer = "habitat-service"
+
+// logs prints the logs of the supervisors of a Habitat. With --all, the logs
+// of all its Pods are interleaved, each line prefixed with the Pod's name.
+func logs(c *cli, args []string) error {
+	name, all, opts, err := parseLogsArgs(args)
+	if err != nil {
+		return err
+	}
+
+	h, err := c.hab.HabitatV1beta1().Habitats(c.namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	pods, err := c.habitatPods(h)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("Habitat %s has no Pods", h.Name)
+	}
+
+	if !all {
+		return c.copyLogs(pods[0], opts, "", c.stdout)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	out := &lockedWriter{w: c.stdout}
+
+	for _, pod := range pods {
+		wg.Add(1)
+		go func(pod apiv1.Pod) {
+			defer wg.Done()
+
+			if err := c.copyLogs(pod, opts, fmt.Sprintf("[%s] ", pod.Name), out); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %v", pod.Name, err))
+				mu.Unlock()
+			}
+		}(pod)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// parseLogsArgs returns the name of the Habitat passed to logs, whether the
+// logs of all its Pods are requested, and the options of the logs.
+func parseLogsArgs(args []string) (string, bool, *apiv1.PodLogOptions, error) {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Print the logs of all the Pods of the Habitat, instead of the first one.")
+	follow := fs.Bool("follow", false, "Stream the logs.")
+	tail := fs.Int64("tail", -1, "Number of recent lines to print from each Pod. (default: all lines)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", false, nil, err
+	}
+
+	if len(positional) != 1 {
+		return "", false, nil, fmt.Errorf("expected the name of a Habitat")
+	}
+
+	opts := &apiv1.PodLogOptions{
+		Container: serviceContainer,
+		Follow:    *follow,
+	}
+	if *tail >= 0 {
+		opts.TailLines = tail
+	}
+
+	return positional[0], *all, opts, nil
+}
+
+// copyLogs copies the logs of pod to w, prefixing each line with prefix.
+func (c *cli) copyLogs(pod apiv1.Pod, opts *apiv1.PodLogOptions, prefix string, w io.Writer) error {
+	stream, err := c.kube.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%s%s\n", prefix, scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// lockedWriter serializes the writes of the goroutines streaming logs, so
+// that their lines aren't mixed up.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubectl-habitat is a kubectl plugin for operating Habitats. Installed in the
+// PATH, it is invoked as `kubectl habitat`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	habclientset "github.com/habitat-sh/habitat-operator/pkg/client/clientset/versioned"
+)
+
+// cli holds the clients and settings shared by the commands.
+type cli struct {
+	kube      kubernetes.Interface
+	hab       habclientset.Interface
+	namespace string
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"list":     {"list [--all-namespaces]", list},
+	"describe": {"describe NAME", describe},
+	"restart":  {"restart NAME", restart},
+	"config":   {"config apply NAME -f FILE", config},
+	"logs":     {"logs NAME [--all] [--follow] [--tail N]", logs},
+	"ring":     {"ring rotate RING", ring},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: kubectl habitat [--kubeconfig FILE] [--context NAME] [--namespace NAMESPACE] COMMAND")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func run() int {
+	flag.Usage = usage
+	kubeconfig := flag.String("kubeconfig", "", "Path to a kubeconfig. (default: the kubeconfig used by kubectl)")
+	kubeContext := flag.String("context", "", "The kubeconfig context to use.")
+	namespace := flag.String("namespace", "", "The namespace of the Habitats. (default: the namespace of the context)")
+	flag.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		return 2
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		return 2
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = *kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{
+		CurrentContext: *kubeContext,
+	})
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	c := &cli{
+		namespace: *namespace,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+	}
+
+	if c.namespace == "" {
+		if c.namespace, _, err = clientConfig.Namespace(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if c.kube, err = kubernetes.NewForConfig(restConfig); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if c.hab, err = habclientset.NewForConfig(restConfig); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := cmd.run(c, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		return 1
+	}
+
+	return 0
+}
+
+// parseArgs parses the flags of fs in args, which may come before or after the
+// positional arguments, as with kubectl. It returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		// Everything after a "--" is positional.
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func main() {
+	os.Exit(run())
+}
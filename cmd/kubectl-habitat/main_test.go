@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args       string
+		positional []string
+		all        bool
+	}{
+		{"foo --all", []string{"foo"}, true},
+		{"--all foo", []string{"foo"}, true},
+		{"foo bar", []string{"foo", "bar"}, false},
+		{"foo -- --all", []string{"foo", "--all"}, false},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		all := fs.Bool("all", false, "")
+
+		positional, err := parseArgs(fs, strings.Fields(tt.args))
+		if err != nil {
+			t.Fatalf("parseArgs(%q) failed: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(positional, tt.positional) || *all != tt.all {
+			t.Errorf("parseArgs(%q) = %v, all = %v, want %v, all = %v", tt.args, positional, *all, tt.positional, tt.all)
+		}
+	}
+}
+
+// The forms of the commands given in the README.
+func TestParseLogsArgs(t *testing.T) {
+	name, all, opts, err := parseLogsArgs(strings.Fields("NAME --all --follow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "NAME" || !all || !opts.Follow {
+		t.Errorf("parseLogsArgs() = %q, all = %v, follow = %v, want NAME with --all and --follow", name, all, opts.Follow)
+	}
+
+	if _, _, _, err := parseLogsArgs(strings.Fields("NAME OTHER")); err == nil {
+		t.Error("parseLogsArgs() of two names returned no error")
+	}
+}
+
+func TestParseConfigArgs(t *testing.T) {
+	name, file, err := parseConfigArgs(strings.Fields("apply NAME -f FILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "NAME" || file != "FILE" {
+		t.Errorf("parseConfigArgs() = %q, %q, want NAME, FILE", name, file)
+	}
+
+	if _, _, err := parseConfigArgs(strings.Fields("apply NAME")); err == nil {
+		t.Error("parseConfigArgs() without a file returned no error")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+// restart restarts the Pods of a Habitat. The operator copies the annotation
+// set on the Habitat to its Pod template, and replaces its Pods as it does for
+// any other change to them.
+func restart(c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a Habitat")
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				habv1beta1.RestartedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.hab.HabitatV1beta1().Habitats(c.namespace).Patch(args[0], types.MergePatchType, patch); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "habitat %q restarted\n", args[0])
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+const (
+	// The operator reads ring keys from Secrets in the namespace of each
+	// Habitat, under this key.
+	ringSecretKey = "ring-key"
+
+	// ringRevisionFormat is the format of the revisions of ring keys.
+	ringRevisionFormat = "20060102150405"
+)
+
+// ringSecretRegexp matches the names of the Secrets containing ring keys, and
+// captures the name of the ring.
+var ringSecretRegexp = regexp.MustCompile(`^([\w_-]+)-\d{14}$`)
+
+// ring manages the ring keys encrypting the gossip of supervisors. Its only
+// subcommand, rotate, generates a new revision of a ring key, and updates the
+// Habitats using the ring to it.
+func ring(c *cli, args []string) error {
+	if len(args) != 2 || args[0] != "rotate" {
+		return fmt.Errorf("expected the rotate subcommand and the name of a ring")
+	}
+	name := args[1]
+
+	secretName := fmt.Sprintf("%s-%s", name, time.Now().UTC().Format(ringRevisionFormat))
+	if !ringSecretRegexp.MatchString(secretName) {
+		return fmt.Errorf("invalid ring name %q", name)
+	}
+
+	key, err := newRingKey(secretName)
+	if err != nil {
+		return err
+	}
+
+	habitats, err := c.hab.HabitatV1beta1().Habitats(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Pods can only mount the Secrets of their namespace, so the key is
+	// stored in each namespace with Habitats using the ring, or in the current
+	// one if there are none.
+	byNamespace := ringHabitats(habitats.Items, name)
+	if len(byNamespace) == 0 {
+		byNamespace[c.namespace] = nil
+	}
+
+	namespaces := make([]string, 0, len(byNamespace))
+	for ns := range byNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"v1beta2": map[string]interface{}{
+				"service": map[string]string{
+					"ringSecretName": secretName,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range namespaces {
+		if err := c.applyRingSecret(ns, secretName, key); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "secret \"%s/%s\" created\n", ns, secretName)
+
+		// The Secret exists before the Habitats are updated to use it.
+		for _, h := range byNamespace[ns] {
+			if _, err := c.hab.HabitatV1beta1().Habitats(h.Namespace).Patch(h.Name, types.MergePatchType, patch); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stdout, "habitat \"%s/%s\" updated\n", h.Namespace, h.Name)
+		}
+	}
+
+	fmt.Fprintln(c.stdout, "Delete the Secrets of the previous revisions once all the Pods have been replaced.")
+
+	return nil
+}
+
+// ringHabitats returns the Habitats among habitats using the ring name, by
+// namespace.
+func ringHabitats(habitats []habv1beta1.Habitat, name string) map[string][]habv1beta1.Habitat {
+	byNamespace := map[string][]habv1beta1.Habitat{}
+
+	for _, h := range habitats {
+		if h.Spec.V1beta2 == nil || h.Spec.V1beta2.Service.RingSecretName == nil {
+			continue
+		}
+
+		m := ringSecretRegexp.FindStringSubmatch(*h.Spec.V1beta2.Service.RingSecretName)
+		if m == nil || m[1] != name {
+			continue
+		}
+
+		byNamespace[h.Namespace] = append(byNamespace[h.Namespace], h)
+	}
+
+	return byNamespace
+}
+
+// applyRingSecret creates, or updates, the Secret named name containing the
+// ring key in namespace.
+func (c *cli) applyRingSecret(namespace, name string, key []byte) error {
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: apiv1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			ringSecretKey: key,
+		},
+	}
+
+	secrets := c.kube.CoreV1().Secrets(namespace)
+	_, err := secrets.Create(secret)
+	if apierrors.IsAlreadyExists(err) {
+		_, err = secrets.Update(secret)
+	}
+
+	return err
+}
+
+// newRingKey returns a new symmetric ring key, in the format written by
+// `hab ring key generate`, for the revision named revision.
+func newRingKey(revision string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("SYM-SEC-1\n%s\n\n%s", revision, base64.StdEncoding.EncodeToString(secret))), nil
+}
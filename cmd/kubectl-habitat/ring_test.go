@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+)
+
+func TestRingHabitats(t *testing.T) {
+	withRing := func(namespace, name, ringSecret string) habv1beta1.Habitat {
+		h := newTestHabitat("redis", nil)
+		h.Namespace = namespace
+		h.Name = name
+		if ringSecret != "" {
+			h.Spec.V1beta2.Service.RingSecretName = &ringSecret
+		}
+
+		return *h
+	}
+
+	habitats := []habv1beta1.Habitat{
+		withRing("team-a", "db", "foo-20180101000000"),
+		withRing("team-b", "cache", "foo-20180101000000"),
+		withRing("team-b", "other", "bar-20180101000000"),
+		withRing("team-c", "none", ""),
+	}
+
+	// The key is stored in the namespace of each Habitat using the ring.
+	got := ringHabitats(habitats, "foo")
+	if len(got) != 2 || len(got["team-a"]) != 1 || len(got["team-b"]) != 1 || got["team-b"][0].Name != "cache" {
+		t.Errorf("ringHabitats() = %v, want db in team-a and cache in team-b", got)
+	}
+}
@@ -16,7 +16,9 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -32,6 +34,18 @@ const (
 
 	TopologyLabel        = "topology"
 	HabitatTopologyLabel = "operator.habitat.sh/topology"
+
+	// LastAppliedAnnotation contains the configuration the operator last
+	// applied to one of the resources it manages.
+	LastAppliedAnnotation = "operator.habitat.sh/last-applied-configuration"
+
+	// DryRunAnnotation, when set to "true" on a Habitat, makes the operator log
+	// the changes it would make to its resources, instead of making them.
+	DryRunAnnotation = "operator.habitat.sh/dry-run"
+
+	// RestartedAtAnnotation is copied from a Habitat to the template of its
+	// Pods, so that changing it, e.g. to the current time, restarts them.
+	RestartedAtAnnotation = "operator.habitat.sh/restarted-at"
 )
 
 // +genclient
@@ -68,6 +82,99 @@ type V1beta2 struct {
 	Env []corev1.EnvVar `json:"env,omitempty"`
 	// +optional
 	PersistentStorage *PersistentStorage `json:"persistentStorage,omitempty"`
+	// PersistentVolumes are additional persistent volumes, mounted in each Pod
+	// along with PersistentStorage.
+	// +optional
+	PersistentVolumes []PersistentVolume `json:"persistentVolumes,omitempty"`
+	// Supervisor contains the settings of the Habitat supervisor running the service.
+	// +optional
+	Supervisor *Supervisor `json:"supervisor,omitempty"`
+	// NetworkPolicy enables the generation of a NetworkPolicy restricting the traffic to
+	// the Pods of the Habitat.
+	// +optional
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+	// PodDisruptionBudget configures the PodDisruptionBudget of the Habitat.
+	// +optional
+	PodDisruptionBudget *PodDisruptionBudget `json:"podDisruptionBudget,omitempty"`
+	// Paused stops the operator from changing the resources of the Habitat, e.g.
+	// while debugging an incident. Its status is still updated. Once resumed,
+	// the resources are updated to match the spec.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// PodDisruptionBudget configures the PodDisruptionBudget the operator creates
+// for each Habitat. At most one of MinAvailable and MaxUnavailable can be set.
+// When neither is set, Habitats with a leader topology keep a quorum of
+// `count/2+1` Pods available, and standalone ones have a MaxUnavailable of 1.
+type PodDisruptionBudget struct {
+	// Disabled prevents the creation of the PodDisruptionBudget.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// MinAvailable is the number, or percentage, of Pods that must remain available
+	// during voluntary disruptions.
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// MaxUnavailable is the number, or percentage, of Pods that can be unavailable
+	// during voluntary disruptions.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// NetworkPolicy configures the NetworkPolicy generated for a Habitat. The
+// NetworkPolicy allows gossip traffic from the other Habitat Pods in the
+// namespace, and traffic to the service's ports from the Habitats in the
+// namespace binding to it. Any other incoming traffic is denied, unless it is
+// allowed by Ingress.
+type NetworkPolicy struct {
+	// Ingress are additional rules allowing traffic to the Pods of the Habitat.
+	// The NetworkPolicyIngressRule type is documented at https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.11/#networkpolicyingressrule-v1-networking.
+	// +optional
+	Ingress []networkingv1.NetworkPolicyIngressRule `json:"ingress,omitempty"`
+}
+
+// Supervisor contains the settings of the Habitat supervisor.
+type Supervisor struct {
+	// ListenGossip is the value of the --listen-gossip flag for the supervisor, in the
+	// `ip:port` format.
+	// Defaults to `0.0.0.0:9638`.
+	// +optional
+	ListenGossip *string `json:"listenGossip,omitempty"`
+	// ListenHTTP is the value of the --listen-http flag for the supervisor, in the
+	// `ip:port` format.
+	// Defaults to `0.0.0.0:9631`.
+	// +optional
+	ListenHTTP *string `json:"listenHTTP,omitempty"`
+	// Org is the value of the --org flag for the supervisor.
+	// +optional
+	Org *string `json:"org,omitempty"`
+	// EventStream configures the supervisor to send events to an event stream.
+	// +optional
+	EventStream *EventStream `json:"eventStream,omitempty"`
+	// ExtraArgs are appended verbatim to the arguments of the supervisor. They can't set
+	// flags which are managed by the operator.
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+}
+
+// EventStream contains the values of the --event-stream-* flags for the supervisor.
+type EventStream struct {
+	// Application is the value of the --event-stream-application flag.
+	Application string `json:"application"`
+	// Environment is the value of the --event-stream-environment flag.
+	Environment string `json:"environment"`
+	// URL is the value of the --event-stream-url flag.
+	URL string `json:"url"`
+	// Site is the value of the --event-stream-site flag.
+	// +optional
+	Site *string `json:"site,omitempty"`
+	// ConnectTimeoutSeconds is the value of the --event-stream-connect-timeout flag.
+	// +optional
+	ConnectTimeoutSeconds *int32 `json:"connectTimeoutSeconds,omitempty"`
+	// TokenSecretRef selects the key of a Secret containing the token used to authenticate
+	// with the event stream. It is passed to the supervisor in the `HAB_AUTH_TOKEN`
+	// environment variable, so that it doesn't appear in the Pod's arguments.
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
 }
 
 // PersistentStorage contains the details of the persistent storage that the
@@ -80,15 +187,77 @@ type PersistentStorage struct {
 	MountPath string `json:"mountPath"`
 	// StorageClassName is the name of the StorageClass that the StatefulSet will request.
 	StorageClassName string `json:"storageClassName"`
+	// AccessModes are the access modes of the volume.
+	// Defaults to `ReadWriteOnce`.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// VolumeMode is the mode of the volume, either `Filesystem` or `Block`. `Block`
+	// volumes are exposed in the Pods as a raw block device at MountPath.
+	// Defaults to `Filesystem`.
+	// +optional
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+	// Labels are added to the PersistentVolumeClaims of the volume.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the PersistentVolumeClaims of the volume.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// DataSource is an existing PersistentVolumeClaim or VolumeSnapshot the volume is
+	// populated from. It requires a cluster supporting volume data sources, and is only
+	// used when the PersistentVolumeClaims are first created.
+	// +optional
+	DataSource *VolumeDataSource `json:"dataSource,omitempty"`
+}
+
+// PersistentVolume is a named persistent volume.
+type PersistentVolume struct {
+	// Name is the name of the volume. The PersistentVolumeClaims of the volume are
+	// named `<name>-<habitat name>-<ordinal>`.
+	Name              string `json:"name"`
+	PersistentStorage `json:",inline"`
+}
+
+// VolumeDataSource references the object a persistent volume is populated from.
+type VolumeDataSource struct {
+	// APIGroup is the group of the object. It must be empty for a
+	// PersistentVolumeClaim, and `snapshot.storage.k8s.io` for a VolumeSnapshot.
+	// +optional
+	APIGroup *string `json:"apiGroup,omitempty"`
+	// Kind is the kind of the object, either `PersistentVolumeClaim` or `VolumeSnapshot`.
+	Kind string `json:"kind"`
+	// Name is the name of the object, in the namespace of the Habitat.
+	Name string `json:"name"`
 }
 
 type HabitatStatus struct {
 	State   HabitatState `json:"state,omitempty"`
 	Message string       `json:"message,omitempty"`
+	// Conditions are the latest observations of the Habitat's state.
+	// +optional
+	Conditions []HabitatCondition `json:"conditions,omitempty"`
 }
 
 type HabitatState string
 
+// HabitatCondition describes the state of a Habitat at a certain point.
+type HabitatCondition struct {
+	// Type of the condition.
+	Type HabitatConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a brief, machine-readable, explanation for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable explanation of the condition's last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type HabitatConditionType string
+
 type ServiceV1beta2 struct {
 	// Group is the value of the --group flag for the hab client.
 	// Defaults to `default`.
@@ -100,6 +269,16 @@ type ServiceV1beta2 struct {
 	// It will be mounted inside the pod as a file, and it will be used by Habitat to configure the service.
 	// +optional
 	ConfigSecretName *string `json:"configSecretName,omitempty"`
+	// ConfigMapRef references a ConfigMap containing a Habitat service's config in TOML format,
+	// under the `user.toml` key. It can be used instead of, or together with, ConfigSecretName.
+	// +optional
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+	// Config is the Habitat service's config, specified inline.
+	// When more than one config source is specified, the operator merges them into a Secret,
+	// which is mounted in place of the individual sources. The inline config takes precedence
+	// over ConfigSecretName, which in turn takes precedence over ConfigMapRef.
+	// +optional
+	Config *ServiceConfig `json:"config,omitempty"`
 	// The name of the secret that contains the ring key.
 	// +optional
 	RingSecretName *string `json:"ringSecretName,omitempty"`
@@ -107,9 +286,40 @@ type ServiceV1beta2 struct {
 	// as a directory.
 	// +optional
 	FilesSecretName *string `json:"filesSecretName,omitempty"`
+	// FilesConfigMapRef references a ConfigMap containing the files directory. When
+	// FilesSecretName is also set, the contents of both are merged.
+	// +optional
+	FilesConfigMapRef *corev1.LocalObjectReference `json:"filesConfigMapRef,omitempty"`
+	// FilesSources are additional sources for the files directory, merged with the contents
+	// of FilesSecretName and FilesConfigMapRef.
+	// The VolumeProjection type is documented at https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.11/#volumeprojection-v1-core.
+	// +optional
+	FilesSources []corev1.VolumeProjection `json:"filesSources,omitempty"`
+	// FilesMode determines how the files directory is populated from its sources.
+	// `copy` copies the files into a writable directory with an init container, so that the
+	// supervisor is able to update them at runtime, while `mount` mounts the sources
+	// read-only, without any init container.
+	// Defaults to `copy`.
+	// +optional
+	FilesMode *FilesMode `json:"filesMode,omitempty"`
+	// FilesInitImage is the image of the init container that copies the files directory in
+	// `copy` mode. The image must provide `sh`, `find` and `cp`.
+	// Defaults to the image of the Habitat service, which already provides them.
+	// +optional
+	FilesInitImage *string `json:"filesInitImage,omitempty"`
 	// Bind is when one service connects to another forming a producer/consumer relationship.
 	// +optional
 	Bind []Bind `json:"bind,omitempty"`
+	// WaitForBinds delays the creation of the service's Pods until all the services it binds
+	// to are running.
+	// +optional
+	WaitForBinds bool `json:"waitForBinds,omitempty"`
+	// Ports are the ports the service listens on. They are declared on the container
+	// running the service, along with the `gossip`, `gossip-udp` and `http` ports of the
+	// supervisor, so that they can be referenced by name. The protocol defaults to TCP.
+	// The ContainerPort type is documented at https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.11/#containerport-v1-core.
+	// +optional
+	Ports []corev1.ContainerPort `json:"ports,omitempty"`
 	// Name is the name of the Habitat service that this Habitat object represents.
 	// This field is used to mount the user.toml file in the correct directory under /hab/user/ in the Pod.
 	Name string `json:"name"`
@@ -119,6 +329,51 @@ type ServiceV1beta2 struct {
 	// Defaults to `stable`.
 	// +optional
 	Channel *string `json:"channel,omitempty"`
+	// Strategy is the value of the --strategy flag for the hab client.
+	// Defaults to `none`.
+	// +optional
+	Strategy *UpdateStrategy `json:"strategy,omitempty"`
+	// UpdateCondition is the value of the --update-condition flag for the hab client.
+	// Defaults to `latest`.
+	// +optional
+	UpdateCondition *UpdateCondition `json:"updateCondition,omitempty"`
+	// BindingMode is the value of the --binding-mode flag for the hab client.
+	// Defaults to `strict`.
+	// +optional
+	BindingMode *BindingMode `json:"bindingMode,omitempty"`
+	// HealthCheckIntervalSeconds is the value of the --health-check-interval flag for the
+	// hab client.
+	// +optional
+	HealthCheckIntervalSeconds *int32 `json:"healthCheckIntervalSeconds,omitempty"`
+	// ShutdownTimeoutSeconds is the value of the --shutdown-timeout flag for the hab client.
+	// The termination grace period of the Pods is extended accordingly.
+	// +optional
+	ShutdownTimeoutSeconds *int32 `json:"shutdownTimeoutSeconds,omitempty"`
+	// Application is the value of the --application flag for the hab client.
+	// It must be set together with Environment.
+	// +optional
+	Application *string `json:"application,omitempty"`
+	// Environment is the value of the --environment flag for the hab client.
+	// It must be set together with Application.
+	// +optional
+	Environment *string `json:"environment,omitempty"`
+	// ConfigFrom is the value of the --config-from flag for the hab client: an absolute
+	// path in the container to a directory containing the service's config templates.
+	// +optional
+	ConfigFrom *string `json:"configFrom,omitempty"`
+}
+
+// ServiceConfig is a Habitat service's config, expressed as a TOML document,
+// as a set of individual values, or both.
+type ServiceConfig struct {
+	// TOML is the service's config in TOML format.
+	// +optional
+	TOML string `json:"toml,omitempty"`
+	// Values maps config keys in dotted notation, e.g. `server.port`, to TOML
+	// values, e.g. `6999` or `"localhost"`. They take precedence over the keys
+	// set in TOML.
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
 }
 
 type Bind struct {
@@ -132,6 +387,14 @@ type Bind struct {
 
 type Topology string
 
+type FilesMode string
+
+type UpdateStrategy string
+
+type UpdateCondition string
+
+type BindingMode string
+
 func (t Topology) String() string {
 	return string(t)
 }
@@ -140,9 +403,38 @@ const (
 	HabitatStateCreated   HabitatState = "Created"
 	HabitatStateProcessed HabitatState = "Processed"
 
+	// HabitatConditionBindsResolved is true when all the services a Habitat binds to are
+	// provided by existing Habitats.
+	HabitatConditionBindsResolved HabitatConditionType = "BindsResolved"
+	// HabitatConditionBindsReady is true when all the services a Habitat binds to are running.
+	HabitatConditionBindsReady HabitatConditionType = "BindsReady"
+	// HabitatConditionBindCycle is true when the binds of a Habitat are part of a cycle.
+	HabitatConditionBindCycle HabitatConditionType = "BindCycle"
+	// HabitatConditionVolumeExpansion is true when all the PersistentVolumeClaims of a
+	// Habitat have been expanded to the size requested in its spec.
+	HabitatConditionVolumeExpansion HabitatConditionType = "VolumeExpansion"
+	// HabitatConditionImmutableFieldsChanged is true when the StatefulSet of a Habitat
+	// can't be updated to match its spec, and has to be deleted by hand.
+	HabitatConditionImmutableFieldsChanged HabitatConditionType = "ImmutableFieldsChanged"
+	// HabitatConditionPaused is true when the reconciliation of a Habitat is paused.
+	HabitatConditionPaused HabitatConditionType = "Paused"
+
 	TopologyStandalone Topology = "standalone"
 	TopologyLeader     Topology = "leader"
 
+	FilesModeCopy  FilesMode = "copy"
+	FilesModeMount FilesMode = "mount"
+
+	UpdateStrategyNone    UpdateStrategy = "none"
+	UpdateStrategyAtOnce  UpdateStrategy = "at-once"
+	UpdateStrategyRolling UpdateStrategy = "rolling"
+
+	UpdateConditionLatest       UpdateCondition = "latest"
+	UpdateConditionTrackChannel UpdateCondition = "track-channel"
+
+	BindingModeStrict  BindingMode = "strict"
+	BindingModeRelaxed BindingMode = "relaxed"
+
 	HabitatKind = "Habitat"
 )
 
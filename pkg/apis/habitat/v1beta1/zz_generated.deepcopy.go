@@ -19,8 +19,10 @@
 package v1beta1
 
 import (
-	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/networking/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -39,13 +41,40 @@ func (in *Bind) DeepCopy() *Bind {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventStream) DeepCopyInto(out *EventStream) {
+	*out = *in
+	if in.Site != nil {
+		in, out := &in.Site, &out.Site
+		*out = new(string)
+		**out = **in
+	}
+	if in.ConnectTimeoutSeconds != nil {
+		in, out := &in.ConnectTimeoutSeconds, &out.ConnectTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	in.TokenSecretRef.DeepCopyInto(&out.TokenSecretRef)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventStream.
+func (in *EventStream) DeepCopy() *EventStream {
+	if in == nil {
+		return nil
+	}
+	out := new(EventStream)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Habitat) DeepCopyInto(out *Habitat) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	if in.CustomVersion != nil {
 		in, out := &in.CustomVersion, &out.CustomVersion
 		*out = new(string)
@@ -72,6 +101,23 @@ func (in *Habitat) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HabitatCondition) DeepCopyInto(out *HabitatCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HabitatCondition.
+func (in *HabitatCondition) DeepCopy() *HabitatCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(HabitatCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HabitatList) DeepCopyInto(out *HabitatList) {
 	*out = *in
@@ -129,6 +175,13 @@ func (in *HabitatSpec) DeepCopy() *HabitatSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HabitatStatus) DeepCopyInto(out *HabitatStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]HabitatCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -142,9 +195,61 @@ func (in *HabitatStatus) DeepCopy() *HabitatStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicy) DeepCopyInto(out *NetworkPolicy) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = make([]v1.NetworkPolicyIngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicy.
+func (in *NetworkPolicy) DeepCopy() *NetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PersistentStorage) DeepCopyInto(out *PersistentStorage) {
 	*out = *in
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]corev1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.VolumeMode != nil {
+		in, out := &in.VolumeMode, &out.VolumeMode
+		*out = new(corev1.PersistentVolumeMode)
+		**out = **in
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DataSource != nil {
+		in, out := &in.DataSource, &out.DataSource
+		*out = new(VolumeDataSource)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -158,6 +263,72 @@ func (in *PersistentStorage) DeepCopy() *PersistentStorage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PersistentVolume) DeepCopyInto(out *PersistentVolume) {
+	*out = *in
+	in.PersistentStorage.DeepCopyInto(&out.PersistentStorage)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistentVolume.
+func (in *PersistentVolume) DeepCopy() *PersistentVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(PersistentVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodDisruptionBudget) DeepCopyInto(out *PodDisruptionBudget) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodDisruptionBudget.
+func (in *PodDisruptionBudget) DeepCopy() *PodDisruptionBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(PodDisruptionBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceConfig.
+func (in *ServiceConfig) DeepCopy() *ServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceV1beta2) DeepCopyInto(out *ServiceV1beta2) {
 	*out = *in
@@ -171,6 +342,16 @@ func (in *ServiceV1beta2) DeepCopyInto(out *ServiceV1beta2) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ServiceConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RingSecretName != nil {
 		in, out := &in.RingSecretName, &out.RingSecretName
 		*out = new(string)
@@ -181,16 +362,83 @@ func (in *ServiceV1beta2) DeepCopyInto(out *ServiceV1beta2) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.FilesConfigMapRef != nil {
+		in, out := &in.FilesConfigMapRef, &out.FilesConfigMapRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.FilesSources != nil {
+		in, out := &in.FilesSources, &out.FilesSources
+		*out = make([]corev1.VolumeProjection, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FilesMode != nil {
+		in, out := &in.FilesMode, &out.FilesMode
+		*out = new(FilesMode)
+		**out = **in
+	}
+	if in.FilesInitImage != nil {
+		in, out := &in.FilesInitImage, &out.FilesInitImage
+		*out = new(string)
+		**out = **in
+	}
 	if in.Bind != nil {
 		in, out := &in.Bind, &out.Bind
 		*out = make([]Bind, len(*in))
 		copy(*out, *in)
 	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]corev1.ContainerPort, len(*in))
+		copy(*out, *in)
+	}
 	if in.Channel != nil {
 		in, out := &in.Channel, &out.Channel
 		*out = new(string)
 		**out = **in
 	}
+	if in.Strategy != nil {
+		in, out := &in.Strategy, &out.Strategy
+		*out = new(UpdateStrategy)
+		**out = **in
+	}
+	if in.UpdateCondition != nil {
+		in, out := &in.UpdateCondition, &out.UpdateCondition
+		*out = new(UpdateCondition)
+		**out = **in
+	}
+	if in.BindingMode != nil {
+		in, out := &in.BindingMode, &out.BindingMode
+		*out = new(BindingMode)
+		**out = **in
+	}
+	if in.HealthCheckIntervalSeconds != nil {
+		in, out := &in.HealthCheckIntervalSeconds, &out.HealthCheckIntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ShutdownTimeoutSeconds != nil {
+		in, out := &in.ShutdownTimeoutSeconds, &out.ShutdownTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Application != nil {
+		in, out := &in.Application, &out.Application
+		*out = new(string)
+		**out = **in
+	}
+	if in.Environment != nil {
+		in, out := &in.Environment, &out.Environment
+		*out = new(string)
+		**out = **in
+	}
+	if in.ConfigFrom != nil {
+		in, out := &in.ConfigFrom, &out.ConfigFrom
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -204,13 +452,54 @@ func (in *ServiceV1beta2) DeepCopy() *ServiceV1beta2 {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Supervisor) DeepCopyInto(out *Supervisor) {
+	*out = *in
+	if in.ListenGossip != nil {
+		in, out := &in.ListenGossip, &out.ListenGossip
+		*out = new(string)
+		**out = **in
+	}
+	if in.ListenHTTP != nil {
+		in, out := &in.ListenHTTP, &out.ListenHTTP
+		*out = new(string)
+		**out = **in
+	}
+	if in.Org != nil {
+		in, out := &in.Org, &out.Org
+		*out = new(string)
+		**out = **in
+	}
+	if in.EventStream != nil {
+		in, out := &in.EventStream, &out.EventStream
+		*out = new(EventStream)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Supervisor.
+func (in *Supervisor) DeepCopy() *Supervisor {
+	if in == nil {
+		return nil
+	}
+	out := new(Supervisor)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *V1beta2) DeepCopyInto(out *V1beta2) {
 	*out = *in
 	in.Service.DeepCopyInto(&out.Service)
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -218,7 +507,29 @@ func (in *V1beta2) DeepCopyInto(out *V1beta2) {
 	if in.PersistentStorage != nil {
 		in, out := &in.PersistentStorage, &out.PersistentStorage
 		*out = new(PersistentStorage)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PersistentVolumes != nil {
+		in, out := &in.PersistentVolumes, &out.PersistentVolumes
+		*out = make([]PersistentVolume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Supervisor != nil {
+		in, out := &in.Supervisor, &out.Supervisor
+		*out = new(Supervisor)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(PodDisruptionBudget)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -232,3 +543,24 @@ func (in *V1beta2) DeepCopy() *V1beta2 {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeDataSource) DeepCopyInto(out *VolumeDataSource) {
+	*out = *in
+	if in.APIGroup != nil {
+		in, out := &in.APIGroup, &out.APIGroup
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeDataSource.
+func (in *VolumeDataSource) DeepCopy() *VolumeDataSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeDataSource)
+	in.DeepCopyInto(out)
+	return out
+}
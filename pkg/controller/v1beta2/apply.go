@@ -0,0 +1,236 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"reflect"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// The Kubernetes versions supported by the operator predate server-side
+// apply, so the resources it manages are applied the way `kubectl apply`
+// does: the operator records the fields it sets in an annotation, and patches
+// the live objects with the changes to them. Fields set by others, such as
+// annotations and sidecars added by admission controllers, are left alone,
+// unless the operator used to set them.
+
+// appliedConfiguration returns the JSON representation of the fields of obj
+// set by the operator, leaving out its status and unset fields.
+func appliedConfiguration(obj runtime.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	delete(m, "status")
+
+	return json.Marshal(pruneNulls(m))
+}
+
+// pruneNulls removes the null values from the maps in v, such as the
+// creation timestamps of the templates of an object.
+func pruneNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = pruneNulls(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = pruneNulls(e)
+		}
+	}
+
+	return v
+}
+
+// setLastApplied records the configuration of obj, as desired by the
+// operator, in its last-applied annotation.
+func setLastApplied(obj runtime.Object) error {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{}
+	for k, v := range m.GetAnnotations() {
+		if k != habv1beta1.LastAppliedAnnotation {
+			annotations[k] = v
+		}
+	}
+	m.SetAnnotations(annotations)
+
+	data, err := appliedConfiguration(obj)
+	if err != nil {
+		return err
+	}
+
+	annotations[habv1beta1.LastAppliedAnnotation] = string(data)
+	m.SetAnnotations(annotations)
+
+	return nil
+}
+
+// applyPatch returns the strategic merge patch applying desired to live, both
+// of the type of dataStruct. It returns nil if live is up to date.
+func applyPatch(desired, live runtime.Object, dataStruct interface{}) ([]byte, error) {
+	if err := setLastApplied(desired); err != nil {
+		return nil, err
+	}
+
+	m, err := meta.Accessor(live)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects created by older versions of the operator have no
+	// annotation, so fields can only be added to or changed in them.
+	original := []byte(m.GetAnnotations()[habv1beta1.LastAppliedAnnotation])
+
+	modified, err := appliedConfiguration(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup, err := strategicpatch.NewPatchMetaFromStruct(dataStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookup, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if sts, ok := desired.(*appsv1.StatefulSet); ok {
+		patch, err = replaceContainerPorts(patch, sts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Patches can contain directives which don't change anything, such as
+	// the order of the containers set by the operator when others were
+	// added.
+	changed, err := patchChanges(current, patch, dataStruct)
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	return patch, nil
+}
+
+// patchChanges returns true if applying patch to current changes it.
+func patchChanges(current, patch []byte, dataStruct interface{}) (bool, error) {
+	if string(patch) == "{}" {
+		return false, nil
+	}
+
+	patched, err := strategicpatch.StrategicMergePatch(current, patch, dataStruct)
+	if err != nil {
+		return false, err
+	}
+
+	var before, after interface{}
+	if err := json.Unmarshal(current, &before); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return false, err
+	}
+
+	return !reflect.DeepEqual(before, after), nil
+}
+
+// replaceContainerPorts makes patch replace the ports of the containers of the
+// StatefulSet desired, instead of merging them. Ports are merged on their
+// number, so of a port declared for both TCP and UDP, such as the gossip port,
+// only one would be kept.
+func replaceContainerPorts(patch []byte, desired *appsv1.StatefulSet) ([]byte, error) {
+	hasPorts := false
+	for _, c := range desired.Spec.Template.Spec.Containers {
+		hasPorts = hasPorts || len(c.Ports) > 0
+	}
+	if !hasPorts {
+		return patch, nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(patch, &m); err != nil {
+		return nil, err
+	}
+
+	spec := patchMap(patchMap(patchMap(m, "spec"), "template"), "spec")
+	containers, _ := spec["containers"].([]interface{})
+
+	for _, c := range desired.Spec.Template.Spec.Containers {
+		if len(c.Ports) == 0 {
+			continue
+		}
+
+		var entry map[string]interface{}
+		for _, e := range containers {
+			if e, ok := e.(map[string]interface{}); ok && e["name"] == c.Name {
+				entry = e
+			}
+		}
+		if entry == nil {
+			entry = map[string]interface{}{"name": c.Name}
+			containers = append(containers, entry)
+		}
+
+		ports := []interface{}{}
+		for _, p := range c.Ports {
+			ports = append(ports, p)
+		}
+		entry["ports"] = append(ports, map[string]interface{}{"$patch": "replace"})
+		delete(entry, "$setElementOrder/ports")
+	}
+	spec["containers"] = containers
+
+	return json.Marshal(m)
+}
+
+// patchMap returns the map under key in the patch m, adding it if it's
+// missing.
+func patchMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, ok := m[key].(map[string]interface{})
+	if !ok {
+		v = map[string]interface{}{}
+		m[key] = v
+	}
+
+	return v
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestAppliedStatefulSet(image string, labels map[string]string) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{}
+	sts.Name = "foo"
+	sts.Labels = labels
+	sts.Spec.Template.Spec.Containers = []apiv1.Container{
+		{Name: "habitat-service", Image: image},
+	}
+
+	return sts
+}
+
+func TestApplyPatch(t *testing.T) {
+	live := newTestAppliedStatefulSet("foo/bar:1", map[string]string{"app": "foo", "tier": "db"})
+	if err := setLastApplied(live); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields set by the API server and by other tools.
+	live.CreationTimestamp = metav1.Now()
+	live.Annotations["sidecar.example.com/injected"] = "true"
+	live.Spec.Template.Spec.Containers[0].TerminationMessagePath = apiv1.TerminationMessagePathDefault
+	live.Spec.Template.Spec.Containers = append(live.Spec.Template.Spec.Containers, apiv1.Container{Name: "sidecar", Image: "sidecar"})
+	live.Status.Replicas = 3
+
+	patch, err := applyPatch(newTestAppliedStatefulSet("foo/bar:1", map[string]string{"app": "foo", "tier": "db"}), live, appsv1.StatefulSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != nil {
+		t.Fatalf("applyPatch() of an unchanged StatefulSet = %s, want nil", patch)
+	}
+
+	patch, err = applyPatch(newTestAppliedStatefulSet("foo/bar:2", map[string]string{"app": "foo"}), live, appsv1.StatefulSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch == nil {
+		t.Fatal("applyPatch() of a changed StatefulSet = nil, want a patch")
+	}
+
+	patched, err := patchedStatefulSet(live, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := patched.Spec.Template.Spec.Containers; len(got) != 2 || got[0].Image != "foo/bar:2" || got[1].Name != "sidecar" {
+		t.Errorf("patched containers = %+v, want the new image and the sidecar", got)
+	}
+	if got := patched.Spec.Template.Spec.Containers[0].TerminationMessagePath; got != apiv1.TerminationMessagePathDefault {
+		t.Errorf("patched terminationMessagePath = %q, want it unchanged", got)
+	}
+	if _, ok := patched.Labels["tier"]; ok {
+		t.Errorf("patched labels = %v, want the label removed from the configuration to be removed", patched.Labels)
+	}
+	if got := patched.Annotations["sidecar.example.com/injected"]; got != "true" {
+		t.Errorf("patched annotations = %v, want the annotation of another tool to be kept", patched.Annotations)
+	}
+
+	// Once applied, the configuration is up to date.
+	patch, err = applyPatch(newTestAppliedStatefulSet("foo/bar:2", map[string]string{"app": "foo"}), patched, appsv1.StatefulSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != nil {
+		t.Errorf("applyPatch() after applying = %s, want nil", patch)
+	}
+}
+
+func TestApplyPatchPortsOnTwoProtocols(t *testing.T) {
+	withPorts := func(port int32) *appsv1.StatefulSet {
+		sts := newTestAppliedStatefulSet("foo/bar:1", nil)
+		sts.Spec.Template.Spec.Containers[0].Ports = []apiv1.ContainerPort{
+			{Name: "gossip", ContainerPort: port, Protocol: apiv1.ProtocolTCP},
+			{Name: "gossip-udp", ContainerPort: port, Protocol: apiv1.ProtocolUDP},
+			{Name: "http", ContainerPort: 9631, Protocol: apiv1.ProtocolTCP},
+		}
+
+		return sts
+	}
+
+	live := withPorts(9638)
+	if err := setLastApplied(live); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := applyPatch(withPorts(9638), live, appsv1.StatefulSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != nil {
+		t.Fatalf("applyPatch() of unchanged ports = %s, want nil", patch)
+	}
+
+	patch, err = applyPatch(withPorts(9000), live, appsv1.StatefulSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch == nil {
+		t.Fatal("applyPatch() of a changed port = nil, want a patch")
+	}
+
+	patched, err := patchedStatefulSet(live, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := withPorts(9000).Spec.Template.Spec.Containers[0].Ports
+	if got := patched.Spec.Template.Spec.Containers[0].Ports; !reflect.DeepEqual(got, want) {
+		t.Errorf("patched ports = %+v, want %+v", got, want)
+	}
+}
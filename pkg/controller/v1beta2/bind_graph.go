@@ -0,0 +1,432 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// The name of the ConfigMap containing the bind graph of a namespace, and
+	// the key under which the graph is stored.
+	bindGraphConfigMapName = "habitat-bind-graph"
+	bindGraphKey           = "graph.json"
+)
+
+// bindGraph is the graph of the binds between Habitats. Habitats are
+// identified by their `namespace/name` key.
+type bindGraph struct {
+	Nodes []bindGraphNode `json:"nodes"`
+	Edges []bindGraphEdge `json:"edges"`
+	// Cycles lists the sets of Habitats whose binds form a cycle.
+	Cycles [][]string `json:"cycles,omitempty"`
+	// Dangling lists the binds which don't refer to any Habitat.
+	Dangling []danglingBind `json:"dangling,omitempty"`
+}
+
+type bindGraphNode struct {
+	Habitat string `json:"habitat"`
+	// ServiceGroup is the `namespace/service.group` key of the service group
+	// run by the Habitat.
+	ServiceGroup string `json:"serviceGroup"`
+}
+
+// bindGraphEdge goes from a consumer to a producer.
+type bindGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Bind string `json:"bind"`
+}
+
+type danglingBind struct {
+	Habitat      string `json:"habitat"`
+	Bind         string `json:"bind"`
+	ServiceGroup string `json:"serviceGroup"`
+}
+
+func habitatKey(h *habv1beta1.Habitat) string {
+	return fmt.Sprintf("%s/%s", h.Namespace, h.Name)
+}
+
+// newBindGraph computes the bind graph of the Habitats in indexer. If
+// namespace is not empty, only the Habitats in that namespace are included.
+// Binds never cross namespaces, so neither do edges nor cycles.
+func newBindGraph(indexer cache.Indexer, namespace string) (*bindGraph, error) {
+	objs := indexer.List()
+	if namespace != "" {
+		var err error
+		if objs, err = indexer.ByIndex(cache.NamespaceIndex, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	var habitats []*habv1beta1.Habitat
+	for _, obj := range objs {
+		h, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			return nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+		}
+
+		if h.Spec.V1beta2 == nil || checkCustomVersionMatch(h.CustomVersion) != nil {
+			continue
+		}
+
+		habitats = append(habitats, h)
+	}
+
+	sort.Slice(habitats, func(i, j int) bool {
+		return habitatKey(habitats[i]) < habitatKey(habitats[j])
+	})
+
+	g := &bindGraph{
+		Nodes: []bindGraphNode{},
+		Edges: []bindGraphEdge{},
+	}
+	adjacency := map[string][]string{}
+
+	for _, h := range habitats {
+		resolved, unresolved, err := resolveBinds(indexer, h)
+		if err != nil {
+			return nil, err
+		}
+
+		key := habitatKey(h)
+		for _, rb := range resolved {
+			adjacency[key] = append(adjacency[key], habitatKey(rb.producer))
+		}
+
+		g.Nodes = append(g.Nodes, bindGraphNode{
+			Habitat:      key,
+			ServiceGroup: providedServiceGroupKey(h),
+		})
+
+		for _, rb := range resolved {
+			g.Edges = append(g.Edges, bindGraphEdge{
+				From: key,
+				To:   habitatKey(rb.producer),
+				Bind: rb.bind.Name,
+			})
+		}
+
+		for _, b := range unresolved {
+			g.Dangling = append(g.Dangling, danglingBind{
+				Habitat:      key,
+				Bind:         b.Name,
+				ServiceGroup: bindTargetKey(h, b),
+			})
+		}
+	}
+
+	g.Cycles = findCycles(adjacency)
+
+	return g, nil
+}
+
+// cycleOf returns the cycle the Habitat identified by key is part of, or nil.
+func (g *bindGraph) cycleOf(key string) []string {
+	for _, c := range g.Cycles {
+		for _, k := range c {
+			if k == key {
+				return c
+			}
+		}
+	}
+
+	return nil
+}
+
+// findCycles returns the strongly connected components of the graph described
+// by adjacency which contain a cycle, using Tarjan's algorithm. Each component
+// is sorted, and so is the returned list.
+func findCycles(adjacency map[string][]string) [][]string {
+	var (
+		index   = 0
+		indexes = map[string]int{}
+		lowlink = map[string]int{}
+		onStack = map[string]bool{}
+		stack   []string
+		cycles  [][]string
+	)
+
+	var connect func(v string)
+	connect = func(v string) {
+		indexes[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		selfLoop := false
+		for _, w := range adjacency[v] {
+			if w == v {
+				selfLoop = true
+			}
+
+			if _, visited := indexes[w]; !visited {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && indexes[w] < lowlink[v] {
+				lowlink[v] = indexes[w]
+			}
+		}
+
+		if lowlink[v] != indexes[v] {
+			return
+		}
+
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	vertices := make([]string, 0, len(adjacency))
+	for v := range adjacency {
+		vertices = append(vertices, v)
+	}
+	sort.Strings(vertices)
+
+	for _, v := range vertices {
+		if _, visited := indexes[v]; !visited {
+			connect(v)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+
+	return cycles
+}
+
+// reconcileBindCycle records in the status of the Habitat h whether its binds
+// are part of a cycle, and returns false if they are.
+func (hc *HabitatController) reconcileBindCycle(h *habv1beta1.Habitat, g *bindGraph) bool {
+	cycle := g.cycleOf(habitatKey(h))
+	if cycle == nil {
+		removeCondition(&h.Status, habv1beta1.HabitatConditionBindCycle)
+		return true
+	}
+
+	msg := fmt.Sprintf("Binds form a cycle between: %s", strings.Join(cycle, ", "))
+	if c := getCondition(h.Status, habv1beta1.HabitatConditionBindCycle); c == nil || c.Message != msg {
+		hc.recorder.Event(h, apiv1.EventTypeWarning, bindCycle, msg)
+	}
+	setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionBindCycle, true, "BindCycle", msg))
+
+	return false
+}
+
+// bindGraphConfigMapKey returns the key of the bind graph ConfigMap of
+// namespace.
+func bindGraphConfigMapKey(namespace string) string {
+	return fmt.Sprintf("%s/%s", namespace, bindGraphConfigMapName)
+}
+
+// habitats returns the Habitats of the nodes of g found in indexer.
+func (g *bindGraph) habitats(indexer cache.Indexer) ([]*habv1beta1.Habitat, error) {
+	var habitats []*habv1beta1.Habitat
+	for _, n := range g.Nodes {
+		obj, exists, err := indexer.GetByKey(n.Habitat)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			habitats = append(habitats, obj.(*habv1beta1.Habitat))
+		}
+	}
+
+	return habitats, nil
+}
+
+// newBindGraphConfigMap returns the ConfigMap containing the bind graph g of
+// namespace. It's owned by the Habitats of the graph, so that it's garbage
+// collected once they're all deleted.
+func newBindGraphConfigMap(namespace string, g *bindGraph, habitats []*habv1beta1.Habitat) (*apiv1.ConfigMap, error) {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	owners := []metav1.OwnerReference{}
+	for _, h := range habitats {
+		owners = append(owners, metav1.OwnerReference{
+			APIVersion: habv1beta1.SchemeGroupVersion.String(),
+			Kind:       habv1beta1.HabitatKind,
+			Name:       h.Name,
+			UID:        h.UID,
+		})
+	}
+
+	return &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bindGraphConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				habv1beta1.HabitatLabel: "true",
+			},
+			OwnerReferences: owners,
+		},
+		Data: map[string]string{
+			bindGraphKey: string(data),
+		},
+	}, nil
+}
+
+// syncBindGraph updates the bind graph ConfigMap of the namespace of the
+// Habitat with the key.
+func (hc *HabitatController) syncBindGraph(key string) error {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	g, err := newBindGraph(hc.habInformer.GetIndexer(), namespace)
+	if err != nil {
+		return err
+	}
+
+	return hc.handleBindGraphConfigMap(namespace, g)
+}
+
+// handleBindGraphConfigMap stores the bind graph g of namespace in a
+// ConfigMap, which is only written when the graph changes, and deleted once
+// the namespace has no Habitats left. Under sharding, the ConfigMap is only
+// written by the replica it's assigned to, so that replicas whose caches
+// briefly disagree don't overwrite each other's graph.
+func (hc *HabitatController) handleBindGraphConfigMap(namespace string, g *bindGraph) error {
+	key := bindGraphConfigMapKey(namespace)
+	if !hc.owns(key) {
+		return nil
+	}
+
+	habitats, err := g.habitats(hc.habInformer.GetIndexer())
+	if err != nil {
+		return err
+	}
+
+	obj, exists, err := hc.cmInformer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if len(habitats) == 0 {
+		if !exists {
+			return nil
+		}
+
+		cm := obj.(*apiv1.ConfigMap)
+		if !isHabitatObject(&cm.ObjectMeta) {
+			return nil
+		}
+
+		err := hc.config.KubernetesClientset.CoreV1().ConfigMaps(namespace).Delete(cm.Name, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &cm.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		level.Debug(hc.logger).Log("msg", "deleted bind graph ConfigMap", "namespace", namespace)
+
+		return nil
+	}
+
+	newCM, err := newBindGraphConfigMap(namespace, g, habitats)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := hc.config.KubernetesClientset.CoreV1().ConfigMaps(namespace).Create(newCM); err != nil {
+			return err
+		}
+
+		level.Debug(hc.logger).Log("msg", "created bind graph ConfigMap", "namespace", namespace)
+
+		return nil
+	}
+
+	cm := obj.(*apiv1.ConfigMap)
+	if !isHabitatObject(&cm.ObjectMeta) {
+		return nil
+	}
+	if cm.Data[bindGraphKey] == newCM.Data[bindGraphKey] && reflect.DeepEqual(cm.OwnerReferences, newCM.OwnerReferences) {
+		return nil
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data = newCM.Data
+	cm.OwnerReferences = newCM.OwnerReferences
+	if _, err := hc.config.KubernetesClientset.CoreV1().ConfigMaps(namespace).Update(cm); err != nil {
+		return err
+	}
+
+	level.Debug(hc.logger).Log("msg", "updated bind graph ConfigMap", "namespace", namespace)
+
+	return nil
+}
+
+// BindGraphHandler returns an http.Handler serving the bind graph of all the
+// Habitats watched by the controller as JSON. The graph can be restricted to a
+// single namespace with the `namespace` query parameter.
+func (hc *HabitatController) BindGraphHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler may be served before the controller has synced its
+		// caches.
+		informer := hc.habInformer
+		if !informer.HasSynced() {
+			http.Error(w, "Habitat cache not synced yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		g, err := newBindGraph(informer.GetIndexer(), r.URL.Query().Get("namespace"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(g); err != nil {
+			level.Error(hc.logger).Log("msg", "Failed to write bind graph", "err", err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name      string
+		adjacency map[string][]string
+		want      [][]string
+	}{
+		{
+			name: "no cycles",
+			adjacency: map[string][]string{
+				"a": {"b", "c"},
+				"b": {"c"},
+			},
+		},
+		{
+			name: "self loop",
+			adjacency: map[string][]string{
+				"a": {"a"},
+				"b": {"a"},
+			},
+			want: [][]string{{"a"}},
+		},
+		{
+			name: "two cycles",
+			adjacency: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"a", "d"},
+				"d": {"e"},
+				"e": {"d"},
+			},
+			want: [][]string{{"a", "b", "c"}, {"d", "e"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCycles(tt.adjacency); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findCycles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBindGraph(t *testing.T) {
+	bind := func(name, service string) habv1beta1.Bind {
+		return habv1beta1.Bind{Name: name, Service: service, Group: "default"}
+	}
+
+	web := newTestHabitat("myproject", "web", "nginx", nil, bind("api", "api"), bind("cache", "redis"))
+	api := newTestHabitat("myproject", "api", "api", nil, bind("auth", "auth"))
+	auth := newTestHabitat("myproject", "auth", "auth", nil, bind("api", "api"))
+	// A Habitat providing the same service in another namespace doesn't
+	// satisfy the binds of myproject.
+	sharedAuth := newTestHabitat("shared", "auth", "auth", nil, bind("api", "api"))
+	indexer := newTestHabitatIndexer(t, web, api, auth, sharedAuth)
+
+	g, err := newBindGraph(indexer, "myproject")
+	if err != nil {
+		t.Fatalf("newBindGraph() error = %v", err)
+	}
+
+	wantNodes := []bindGraphNode{
+		{Habitat: "myproject/api", ServiceGroup: "myproject/api.default"},
+		{Habitat: "myproject/auth", ServiceGroup: "myproject/auth.default"},
+		{Habitat: "myproject/web", ServiceGroup: "myproject/nginx.default"},
+	}
+	if !reflect.DeepEqual(g.Nodes, wantNodes) {
+		t.Errorf("newBindGraph() nodes = %v, want %v", g.Nodes, wantNodes)
+	}
+
+	wantEdges := []bindGraphEdge{
+		{From: "myproject/api", To: "myproject/auth", Bind: "auth"},
+		{From: "myproject/auth", To: "myproject/api", Bind: "api"},
+		{From: "myproject/web", To: "myproject/api", Bind: "api"},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("newBindGraph() edges = %v, want %v", g.Edges, wantEdges)
+	}
+
+	wantDangling := []danglingBind{
+		{Habitat: "myproject/web", Bind: "cache", ServiceGroup: "myproject/redis.default"},
+	}
+	if !reflect.DeepEqual(g.Dangling, wantDangling) {
+		t.Errorf("newBindGraph() dangling = %v, want %v", g.Dangling, wantDangling)
+	}
+
+	wantCycles := [][]string{{"myproject/api", "myproject/auth"}}
+	if !reflect.DeepEqual(g.Cycles, wantCycles) {
+		t.Errorf("newBindGraph() cycles = %v, want %v", g.Cycles, wantCycles)
+	}
+	if c := g.cycleOf("myproject/web"); c != nil {
+		t.Errorf("cycleOf(myproject/web) = %v, want nil", c)
+	}
+
+	g, err = newBindGraph(indexer, "shared")
+	if err != nil {
+		t.Fatalf("newBindGraph() error = %v", err)
+	}
+	if len(g.Cycles) != 0 {
+		t.Errorf("newBindGraph() cycles in shared = %v, want none", g.Cycles)
+	}
+	wantDangling = []danglingBind{
+		{Habitat: "shared/auth", Bind: "api", ServiceGroup: "shared/api.default"},
+	}
+	if !reflect.DeepEqual(g.Dangling, wantDangling) {
+		t.Errorf("newBindGraph() dangling in shared = %v, want %v", g.Dangling, wantDangling)
+	}
+}
+
+// The controller has no clientset, so any write of the ConfigMap panics.
+func TestHandleBindGraphConfigMapUnchanged(t *testing.T) {
+	h := newTestHabitat("myproject", "web", "nginx", nil)
+	h.UID = types.UID("web-uid")
+
+	habInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &habv1beta1.Habitat{}, 0, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		serviceGroupIndex:    serviceGroupIndexFunc,
+		bindTargetIndex:      bindTargetIndexFunc,
+	})
+	if err := habInformer.GetIndexer().Add(h); err != nil {
+		t.Fatal(err)
+	}
+	cmInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &apiv1.ConfigMap{}, 0, cache.Indexers{})
+
+	hc := &HabitatController{
+		logger:      log.NewNopLogger(),
+		habInformer: habInformer,
+		cmInformer:  cmInformer,
+	}
+
+	g, err := newBindGraph(habInformer.GetIndexer(), h.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ConfigMap is assigned to another replica.
+	hc.shards = newShardMembership(ShardingConfig{Identity: "a"}, nil, hc.logger, nil)
+	hc.shards.ring = newHashRing([]string{"b"})
+	if err := hc.handleBindGraphConfigMap(h.Namespace, g); err != nil {
+		t.Errorf("handleBindGraphConfigMap() on another replica error = %v", err)
+	}
+	hc.shards = nil
+
+	// The ConfigMap is up to date.
+	cm, err := newBindGraphConfigMap(h.Namespace, g, []*habv1beta1.Habitat{h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmInformer.GetIndexer().Add(cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.handleBindGraphConfigMap(h.Namespace, g); err != nil {
+		t.Errorf("handleBindGraphConfigMap() of an unchanged graph error = %v", err)
+	}
+	if got := cm.OwnerReferences; len(got) != 1 || got[0].UID != h.UID {
+		t.Errorf("owner references = %v, want the Habitat", got)
+	}
+
+	// A ConfigMap with the same name which wasn't created by the operator is
+	// left alone.
+	other := &apiv1.ConfigMap{}
+	other.Name = bindGraphConfigMapName
+	other.Namespace = h.Namespace
+	if err := cmInformer.GetIndexer().Update(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.handleBindGraphConfigMap(h.Namespace, g); err != nil {
+		t.Errorf("handleBindGraphConfigMap() with a ConfigMap of another owner error = %v", err)
+	}
+}
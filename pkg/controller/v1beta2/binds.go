@@ -0,0 +1,305 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// defaultGroup is the group services are assigned to by the supervisor,
+	// when none is specified.
+	defaultGroup = "default"
+
+	// Names of the indexes on the Habitat informer, mapping service groups to
+	// the Habitats providing them and to the Habitats binding to them.
+	serviceGroupIndex = "serviceGroup"
+	bindTargetIndex   = "bindTarget"
+)
+
+// resolvedBind is a bind together with the Habitat providing its service.
+type resolvedBind struct {
+	bind     habv1beta1.Bind
+	producer *habv1beta1.Habitat
+}
+
+// serviceGroupKey returns the key identifying the service group `service.group`
+// running in namespace.
+func serviceGroupKey(namespace, service, group string) string {
+	return fmt.Sprintf("%s/%s.%s", namespace, service, group)
+}
+
+// providedServiceGroupKey returns the key of the service group run by the
+// Habitat h.
+func providedServiceGroupKey(h *habv1beta1.Habitat) string {
+	group := defaultGroup
+	if g := h.Spec.V1beta2.Service.Group; g != nil {
+		group = *g
+	}
+
+	return serviceGroupKey(h.Namespace, h.Spec.V1beta2.Service.Name, group)
+}
+
+// validateBinds checks that the binds of the Habitat h are complete.
+func validateBinds(h habv1beta1.Habitat) error {
+	for _, b := range h.Spec.V1beta2.Service.Bind {
+		if b.Name == "" || b.Service == "" || b.Group == "" {
+			return fmt.Errorf("bind must specify name, service and group: %+v", b)
+		}
+	}
+
+	return nil
+}
+
+// bindTargetKey returns the key of the service group the bind b of the
+// Habitat h refers to. Binds always refer to services in the namespace of h,
+// as the supervisors only gossip with the ones of their namespace.
+func bindTargetKey(h *habv1beta1.Habitat, b habv1beta1.Bind) string {
+	return serviceGroupKey(h.Namespace, b.Service, b.Group)
+}
+
+func serviceGroupIndexFunc(obj interface{}) ([]string, error) {
+	h, ok := obj.(*habv1beta1.Habitat)
+	if !ok {
+		return nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+	}
+	if h.Spec.V1beta2 == nil {
+		return nil, nil
+	}
+
+	return []string{providedServiceGroupKey(h)}, nil
+}
+
+func bindTargetIndexFunc(obj interface{}) ([]string, error) {
+	h, ok := obj.(*habv1beta1.Habitat)
+	if !ok {
+		return nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+	}
+	if h.Spec.V1beta2 == nil {
+		return nil, nil
+	}
+
+	var keys []string
+	for _, b := range h.Spec.V1beta2.Service.Bind {
+		keys = append(keys, bindTargetKey(h, b))
+	}
+
+	return keys, nil
+}
+
+// resolveBinds looks up the Habitats providing the services the binds of the
+// Habitat h refer to, returning the binds that could be resolved along with
+// their producers, and the ones that could not.
+func resolveBinds(indexer cache.Indexer, h *habv1beta1.Habitat) ([]resolvedBind, []habv1beta1.Bind, error) {
+	var resolved []resolvedBind
+	var unresolved []habv1beta1.Bind
+
+	for _, b := range h.Spec.V1beta2.Service.Bind {
+		objs, err := indexer.ByIndex(serviceGroupIndex, bindTargetKey(h, b))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var producer *habv1beta1.Habitat
+		for _, obj := range objs {
+			p, ok := obj.(*habv1beta1.Habitat)
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+			}
+
+			// Only Habitats handled by this controller are taken into account.
+			if checkCustomVersionMatch(p.CustomVersion) == nil {
+				producer = p
+				break
+			}
+		}
+
+		if producer == nil {
+			unresolved = append(unresolved, b)
+			continue
+		}
+
+		resolved = append(resolved, resolvedBind{bind: b, producer: producer})
+	}
+
+	return resolved, unresolved, nil
+}
+
+// formatBinds returns a human-readable list of binds.
+func formatBinds(h *habv1beta1.Habitat, binds []habv1beta1.Bind) string {
+	var s []string
+	for _, b := range binds {
+		s = append(s, fmt.Sprintf("%s (%s)", b.Name, bindTargetKey(h, b)))
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// pendingBinds returns the binds of the Habitat h which don't refer to any
+// Habitat, and, if h waits for its binds, the ones whose services aren't
+// running yet, unresolved binds included.
+func (hc *HabitatController) pendingBinds(h *habv1beta1.Habitat) (unresolved, waiting []habv1beta1.Bind, err error) {
+	resolved, unresolved, err := resolveBinds(hc.habInformer.GetIndexer(), h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !h.Spec.V1beta2.Service.WaitForBinds {
+		return unresolved, nil, nil
+	}
+
+	// Unresolved binds can't be ready.
+	waiting = append(waiting, unresolved...)
+	for _, rb := range resolved {
+		ready, err := hc.producerReady(rb.producer)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !ready {
+			waiting = append(waiting, rb.bind)
+		}
+	}
+
+	return unresolved, waiting, nil
+}
+
+// reconcileBinds resolves the binds of the Habitat h and records the outcome
+// in its status. It returns false if the creation of h's Pods has to be
+// delayed because of its binds.
+func (hc *HabitatController) reconcileBinds(h *habv1beta1.Habitat) (bool, error) {
+	hs := h.Spec.V1beta2
+
+	if len(hs.Service.Bind) == 0 {
+		removeCondition(&h.Status, habv1beta1.HabitatConditionBindsResolved)
+		removeCondition(&h.Status, habv1beta1.HabitatConditionBindsReady)
+		return true, nil
+	}
+
+	unresolved, waiting, err := hc.pendingBinds(h)
+	if err != nil {
+		return false, err
+	}
+
+	if len(unresolved) > 0 {
+		msg := fmt.Sprintf("No Habitat provides the services of the binds: %s", formatBinds(h, unresolved))
+		// Only emit an event when the binds become unresolved, or the list of
+		// unresolved binds changes.
+		if c := getCondition(h.Status, habv1beta1.HabitatConditionBindsResolved); c == nil || c.Message != msg {
+			hc.recorder.Event(h, apiv1.EventTypeWarning, bindsUnresolved, msg)
+		}
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionBindsResolved, false, "UnresolvedBinds", msg))
+	} else {
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionBindsResolved, true, "BindsResolved", "All binds are resolved"))
+	}
+
+	if !hs.Service.WaitForBinds {
+		removeCondition(&h.Status, habv1beta1.HabitatConditionBindsReady)
+		return true, nil
+	}
+
+	if len(waiting) > 0 {
+		msg := fmt.Sprintf("Waiting for the services of the binds: %s", formatBinds(h, waiting))
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionBindsReady, false, "WaitingForBinds", msg))
+		return false, nil
+	}
+
+	setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionBindsReady, true, "BindsReady", "The services of all binds are running"))
+
+	return true, nil
+}
+
+// producerReady returns true if at least one of the Pods of the Habitat h is
+// ready.
+func (hc *HabitatController) producerReady(h *habv1beta1.Habitat) (bool, error) {
+	obj, exists, err := hc.stsInformer.GetStore().GetByKey(fmt.Sprintf("%s/%s", h.Namespace, h.Name))
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+
+	sts, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		return false, fmt.Errorf("unknown object type in StatefulSet cache: %v", obj)
+	}
+
+	return sts.Status.ReadyReplicas > 0, nil
+}
+
+// enqueueConsumers enqueues all the Habitats binding, directly or through
+// other Habitats, to the service provided by the Habitat h. Following the
+// binds transitively ensures that all the members of a bind cycle are
+// notified when the cycle is formed or broken.
+func (hc *HabitatController) enqueueConsumers(h *habv1beta1.Habitat) {
+	visited := map[string]bool{}
+	pending := []*habv1beta1.Habitat{h}
+
+	for len(pending) > 0 {
+		p := pending[0]
+		pending = pending[1:]
+
+		if p.Spec.V1beta2 == nil {
+			continue
+		}
+
+		objs, err := hc.habInformer.GetIndexer().ByIndex(bindTargetIndex, providedServiceGroupKey(p))
+		if err != nil {
+			level.Error(hc.logger).Log("msg", "Failed to look up consumers", "err", err)
+			return
+		}
+
+		for _, obj := range objs {
+			c, ok := obj.(*habv1beta1.Habitat)
+			if !ok {
+				level.Error(hc.logger).Log("msg", "Failed to type assert Habitat", "obj", obj)
+				continue
+			}
+
+			if key := habitatKey(c); !visited[key] {
+				visited[key] = true
+				hc.enqueue(c)
+				pending = append(pending, c)
+			}
+		}
+	}
+}
+
+// enqueueProducers enqueues all the Habitats providing the services the
+// Habitat h binds to.
+func (hc *HabitatController) enqueueProducers(h *habv1beta1.Habitat) {
+	if h.Spec.V1beta2 == nil {
+		return
+	}
+
+	resolved, _, err := resolveBinds(hc.habInformer.GetIndexer(), h)
+	if err != nil {
+		level.Error(hc.logger).Log("msg", "Failed to look up producers", "err", err)
+		return
+	}
+
+	for _, rb := range resolved {
+		hc.enqueue(rb.producer)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestHabitat(namespace, name, service string, group *string, binds ...habv1beta1.Bind) *habv1beta1.Habitat {
+	return &habv1beta1.Habitat{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		CustomVersion: strToPtr("v1beta2"),
+		Spec: habv1beta1.HabitatSpec{
+			V1beta2: &habv1beta1.V1beta2{
+				Service: habv1beta1.ServiceV1beta2{
+					Name:  service,
+					Group: group,
+					Bind:  binds,
+				},
+			},
+		},
+	}
+}
+
+func newTestHabitatIndexer(t *testing.T, habitats ...*habv1beta1.Habitat) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		serviceGroupIndex:    serviceGroupIndexFunc,
+		bindTargetIndex:      bindTargetIndexFunc,
+	})
+
+	for _, h := range habitats {
+		if err := indexer.Add(h); err != nil {
+			t.Fatalf("could not add Habitat to indexer: %v", err)
+		}
+	}
+
+	return indexer
+}
+
+func TestResolveBinds(t *testing.T) {
+	db := newTestHabitat("myproject", "db", "postgresql", nil)
+	cache := newTestHabitat("shared", "cache", "redis", strToPtr("redisdb"))
+	indexer := newTestHabitatIndexer(t, db, cache)
+
+	dbBind := habv1beta1.Bind{Name: "db", Service: "postgresql", Group: "default"}
+	cacheBind := habv1beta1.Bind{Name: "cache", Service: "redis", Group: "redisdb"}
+	wrongGroupBind := habv1beta1.Bind{Name: "db", Service: "postgresql", Group: "other"}
+
+	tests := []struct {
+		name           string
+		binds          []habv1beta1.Bind
+		wantResolved   []resolvedBind
+		wantUnresolved []habv1beta1.Bind
+	}{
+		{
+			name:         "bind in the same namespace, default group",
+			binds:        []habv1beta1.Bind{dbBind},
+			wantResolved: []resolvedBind{{bind: dbBind, producer: db}},
+		},
+		{
+			name:           "service only provided in another namespace",
+			binds:          []habv1beta1.Bind{cacheBind},
+			wantUnresolved: []habv1beta1.Bind{cacheBind},
+		},
+		{
+			name:           "mixed resolved and unresolved binds",
+			binds:          []habv1beta1.Bind{dbBind, wrongGroupBind},
+			wantResolved:   []resolvedBind{{bind: dbBind, producer: db}},
+			wantUnresolved: []habv1beta1.Bind{wrongGroupBind},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHabitat("myproject", "web", "nginx", nil, tt.binds...)
+
+			resolved, unresolved, err := resolveBinds(indexer, h)
+			if err != nil {
+				t.Fatalf("resolveBinds() error = %v", err)
+			}
+			if !reflect.DeepEqual(resolved, tt.wantResolved) {
+				t.Errorf("resolveBinds() resolved = %v, want %v", resolved, tt.wantResolved)
+			}
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("resolveBinds() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestValidateBinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		bind    habv1beta1.Bind
+		wantErr bool
+	}{
+		{
+			name: "complete",
+			bind: habv1beta1.Bind{Name: "db", Service: "postgresql", Group: "default"},
+		},
+		{
+			name:    "missing group",
+			bind:    habv1beta1.Bind{Name: "db", Service: "postgresql"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHabitat("myproject", "web", "nginx", nil, tt.bind)
+			err := validateBinds(*h)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBinds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
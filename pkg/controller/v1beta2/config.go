@@ -0,0 +1,298 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// needsRenderedUserConfig returns true if the config of the Habitat service
+// has to be rendered by the operator, i.e. if it is specified inline or if it
+// comes from more than one source.
+func needsRenderedUserConfig(s habv1beta1.ServiceV1beta2) bool {
+	return s.Config != nil || (s.ConfigSecretName != nil && s.ConfigMapRef != nil)
+}
+
+// userConfigSecretName returns the name of the Secret the operator renders the
+// service config into.
+func userConfigSecretName(h *habv1beta1.Habitat) string {
+	return fmt.Sprintf("%s-%s", h.Name, userConfigFilename)
+}
+
+// renderUserTOML merges the TOML documents in bases, in order, followed by the
+// inline config c, if any, and returns the resulting TOML document.
+func renderUserTOML(bases [][]byte, c *habv1beta1.ServiceConfig) ([]byte, error) {
+	config := map[string]interface{}{}
+
+	for _, b := range bases {
+		base := map[string]interface{}{}
+		if _, err := toml.Decode(string(b), &base); err != nil {
+			return nil, fmt.Errorf("could not parse base config: %v", err)
+		}
+		mergeTOML(config, base)
+	}
+
+	if c != nil {
+		inline := map[string]interface{}{}
+		if _, err := toml.Decode(c.TOML, &inline); err != nil {
+			return nil, fmt.Errorf("could not parse config.toml: %v", err)
+		}
+		mergeTOML(config, inline)
+
+		// Sort the keys so that errors are reported deterministically.
+		keys := make([]string, 0, len(c.Values))
+		for k := range c.Values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := setTOMLValue(config, k, c.Values[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(config); err != nil {
+		return nil, fmt.Errorf("could not render config: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeTOML recursively merges the TOML tables in src into dst. Values in src
+// take precedence over those in dst.
+func mergeTOML(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcTable, srcOK := v.(map[string]interface{})
+		dstTable, dstOK := dst[k].(map[string]interface{})
+		if srcOK && dstOK {
+			mergeTOML(dstTable, srcTable)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
+
+// setTOMLValue parses the TOML value raw and sets it in config under key,
+// which is expressed in dotted notation. Missing tables are created.
+func setTOMLValue(config map[string]interface{}, key, raw string) error {
+	parts := strings.Split(key, ".")
+	for _, p := range parts {
+		if p == "" {
+			return fmt.Errorf("malformed config key: %q", key)
+		}
+	}
+
+	v := map[string]interface{}{}
+	if _, err := toml.Decode(fmt.Sprintf("v = %s", raw), &v); err != nil {
+		return fmt.Errorf("malformed value for config key %q: %v", key, err)
+	}
+
+	table := config
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := table[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			table[p] = next
+		}
+		table = next
+	}
+	table[parts[len(parts)-1]] = v["v"]
+
+	return nil
+}
+
+// newUserConfigSecret returns the Secret containing the rendered user.toml
+// file for the Habitat h.
+func newUserConfigSecret(h *habv1beta1.Habitat, userTOML []byte) *apiv1.Secret {
+	return &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      userConfigSecretName(h),
+			Namespace: h.Namespace,
+			Labels: map[string]string{
+				habv1beta1.HabitatLabel:     "true",
+				habv1beta1.HabitatNameLabel: h.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				metav1.OwnerReference{
+					APIVersion: habv1beta1.SchemeGroupVersion.String(),
+					Kind:       habv1beta1.HabitatKind,
+					Name:       h.Name,
+					UID:        h.UID,
+				},
+			},
+		},
+		Type: apiv1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			userTOMLFile: userTOML,
+		},
+	}
+}
+
+// renderUserConfigSecret renders the config sources of the Habitat h into the
+// Secret mounted in its Pods.
+func (hc *HabitatController) renderUserConfigSecret(h *habv1beta1.Habitat) (*apiv1.Secret, error) {
+	hs := h.Spec.V1beta2
+
+	// Sources are listed in increasing order of precedence.
+	var bases [][]byte
+	if ref := hs.Service.ConfigMapRef; ref != nil {
+		cm, err := hc.objects.getConfigMap(h.Namespace, ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, []byte(cm.Data[userTOMLFile]))
+	}
+
+	if hs.Service.ConfigSecretName != nil {
+		s, err := hc.objects.getSecret(h.Namespace, *hs.Service.ConfigSecretName)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, s.Data[userTOMLFile])
+	}
+
+	userTOML, err := renderUserTOML(bases, hs.Service.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserConfigSecret(h, userTOML), nil
+}
+
+// deleteUserConfigSecret deletes the Secret previously rendered for the
+// Habitat h, if any. Secrets with the same name which weren't created for h
+// are left alone.
+func (hc *HabitatController) deleteUserConfigSecret(h *habv1beta1.Habitat) error {
+	obj, exists, err := hc.secretInformer.GetStore().GetByKey(fmt.Sprintf("%s/%s", h.Namespace, userConfigSecretName(h)))
+	if err != nil || !exists {
+		return err
+	}
+
+	s, ok := obj.(*apiv1.Secret)
+	if !ok {
+		return fmt.Errorf("unknown object type in Secret cache: %v", obj)
+	}
+	if !ownedByHabitat(s, h) {
+		return nil
+	}
+
+	err = hc.config.KubernetesClientset.CoreV1().Secrets(h.Namespace).Delete(s.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &s.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// errUserConfigSecretNotOwned returns the error reported when the Secret s,
+// which has the name of a rendered config Secret, wasn't created by the operator.
+func errUserConfigSecretNotOwned(s *apiv1.Secret) error {
+	return fmt.Errorf("the Secret %s already exists and doesn't belong to the Habitat", s.Name)
+}
+
+// ownedByHabitat returns whether obj is owned by the Habitat h.
+func ownedByHabitat(obj metav1.Object, h *habv1beta1.Habitat) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == habv1beta1.HabitatKind && ref.UID == h.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleUserConfig renders the config sources of the Habitat h into a Secret,
+// creating or updating it as needed. If h's config does not need rendering, a
+// previously rendered Secret is deleted.
+func (hc *HabitatController) handleUserConfig(h *habv1beta1.Habitat) error {
+	hs := h.Spec.V1beta2
+
+	if !needsRenderedUserConfig(hs.Service) {
+		return hc.deleteUserConfigSecret(h)
+	}
+
+	// Don't render the config into a Secret someone else created, as the
+	// StatefulSet would then mount contents the operator doesn't control.
+	obj, exists, err := hc.secretInformer.GetStore().GetByKey(fmt.Sprintf("%s/%s", h.Namespace, userConfigSecretName(h)))
+	if err != nil {
+		return err
+	}
+	if exists {
+		s, ok := obj.(*apiv1.Secret)
+		if !ok {
+			return fmt.Errorf("unknown object type in Secret cache: %v", obj)
+		}
+		if !ownedByHabitat(s, h) {
+			return errUserConfigSecretNotOwned(s)
+		}
+	}
+
+	newSecret, err := hc.renderUserConfigSecret(h)
+	if err != nil {
+		return err
+	}
+
+	secrets := hc.config.KubernetesClientset.CoreV1().Secrets(h.Namespace)
+	if _, err := secrets.Create(newSecret); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+
+		oldSecret, err := secrets.Get(newSecret.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !ownedByHabitat(oldSecret, h) {
+			return errUserConfigSecretNotOwned(oldSecret)
+		}
+
+		if reflect.DeepEqual(oldSecret.Data, newSecret.Data) {
+			return nil
+		}
+
+		oldSecret.Data = newSecret.Data
+		if _, err := secrets.Update(oldSecret); err != nil {
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", messageUserConfigUpdated, "name", newSecret.Name)
+		hc.recorder.Event(h, apiv1.EventTypeNormal, userConfigUpdated, messageUserConfigUpdated)
+
+		return nil
+	}
+
+	level.Info(hc.logger).Log("msg", messageUserConfigCreated, "name", newSecret.Name)
+	hc.recorder.Event(h, apiv1.EventTypeNormal, userConfigCreated, messageUserConfigCreated)
+
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRenderUserTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		bases   []string
+		config  *habv1beta1.ServiceConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "inline TOML only",
+			config: &habv1beta1.ServiceConfig{
+				TOML: "port = 6999\n",
+			},
+			want: "port = 6999\n",
+		},
+		{
+			name: "values create nested tables",
+			config: &habv1beta1.ServiceConfig{
+				Values: map[string]string{
+					"server.port": "6999",
+					"server.host": `"localhost"`,
+				},
+			},
+			want: "[server]\n  host = \"localhost\"\n  port = 6999\n",
+		},
+		{
+			name: "values take precedence over inline TOML",
+			config: &habv1beta1.ServiceConfig{
+				TOML: "port = 6999\nbind = \"0.0.0.0\"\n",
+				Values: map[string]string{
+					"port": "7000",
+				},
+			},
+			want: "bind = \"0.0.0.0\"\nport = 7000\n",
+		},
+		{
+			name:  "inline config is merged on top of base",
+			bases: []string{"port = 6379\n\n[tls]\nenabled = false\ncert = \"/cert\"\n"},
+			config: &habv1beta1.ServiceConfig{
+				TOML: "[tls]\nenabled = true\n",
+			},
+			want: "port = 6379\n\n[tls]\n  cert = \"/cert\"\n  enabled = true\n",
+		},
+		{
+			name: "bases are merged in order",
+			bases: []string{
+				"port = 6379\nbind = \"0.0.0.0\"\n",
+				"port = 6999\n",
+			},
+			want: "bind = \"0.0.0.0\"\nport = 6999\n",
+		},
+		{
+			name:    "malformed base",
+			bases:   []string{"port = "},
+			wantErr: true,
+		},
+		{
+			name: "malformed inline TOML",
+			config: &habv1beta1.ServiceConfig{
+				TOML: "port = ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed value",
+			config: &habv1beta1.ServiceConfig{
+				Values: map[string]string{
+					"host": "localhost",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed key",
+			config: &habv1beta1.ServiceConfig{
+				Values: map[string]string{
+					"server..port": "6999",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bases [][]byte
+			for _, b := range tt.bases {
+				bases = append(bases, []byte(b))
+			}
+
+			got, err := renderUserTOML(bases, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("renderUserTOML() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err != nil {
+				t.Logf("renderUserTOML() failed as expected with error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderUserTOML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteUserConfigSecret(t *testing.T) {
+	h := newTestHabitat("default", "foo", "redis", nil)
+	h.UID = "foo-uid"
+
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &apiv1.Secret{}, 0, cache.Indexers{})
+	// The controller has no clientset, so any request to the API server
+	// would fail the test.
+	hc := &HabitatController{secretInformer: informer}
+
+	if err := hc.deleteUserConfigSecret(h); err != nil {
+		t.Errorf("deleteUserConfigSecret() without a Secret failed: %v", err)
+	}
+
+	// A Secret created by someone else with the same name is kept.
+	s := &apiv1.Secret{}
+	s.Name = userConfigSecretName(h)
+	s.Namespace = h.Namespace
+	if err := informer.GetIndexer().Add(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.deleteUserConfigSecret(h); err != nil {
+		t.Errorf("deleteUserConfigSecret() with a Secret of another owner failed: %v", err)
+	}
+
+	if !ownedByHabitat(newUserConfigSecret(h, nil), h) {
+		t.Error("ownedByHabitat() = false for the rendered Secret, want true")
+	}
+}
+
+func TestHandleUserConfigNotOwned(t *testing.T) {
+	h := newTestHabitat("default", "foo", "redis", nil)
+	h.UID = "foo-uid"
+	h.Spec.V1beta2.Service.Config = &habv1beta1.ServiceConfig{}
+
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &apiv1.Secret{}, 0, cache.Indexers{})
+	s := &apiv1.Secret{}
+	s.Name = userConfigSecretName(h)
+	s.Namespace = h.Namespace
+	if err := informer.GetIndexer().Add(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// The controller has no clientset, so overwriting the Secret would fail
+	// the test.
+	hc := &HabitatController{secretInformer: informer}
+
+	if err := hc.handleUserConfig(h); err == nil {
+		t.Error("handleUserConfig() with a Secret of another owner succeeded, want an error")
+	}
+}
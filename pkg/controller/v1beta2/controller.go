@@ -34,8 +34,8 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -47,8 +47,6 @@ import (
 )
 
 const (
-	resyncPeriod = 1 * time.Minute
-
 	userTOMLFile = "user.toml"
 	configMapDir = "/habitat-operator"
 
@@ -71,23 +69,57 @@ const (
 	controllerAgentName = "habitat-controller"
 
 	// Events.
-	validationFailed = "ValidationFailed"
-	cmCreated        = "ConfigMapCreated"
-	cmUpdated        = "ConfigMapUpdated"
-	cmFailed         = "ConfigMapCreationFailed"
-	stsCreated       = "StatefulSetCreated"
-	stsFailed        = "StatefulSetCreationFailed"
+	validationFailed  = "ValidationFailed"
+	cmCreated         = "ConfigMapCreated"
+	cmUpdated         = "ConfigMapUpdated"
+	cmFailed          = "ConfigMapCreationFailed"
+	stsCreated        = "StatefulSetCreated"
+	stsFailed         = "StatefulSetCreationFailed"
+	userConfigCreated = "UserConfigCreated"
+	userConfigUpdated = "UserConfigUpdated"
+	userConfigFailed  = "UserConfigFailed"
+	bindsUnresolved   = "BindsUnresolved"
+	bindCycle         = "BindCycle"
+	bindGraphFailed   = "BindGraphUpdateFailed"
+	nwpCreated        = "NetworkPolicyCreated"
+	nwpFailed         = "NetworkPolicyFailed"
+	pdbCreated        = "PodDisruptionBudgetCreated"
+	pdbFailed         = "PodDisruptionBudgetFailed"
+	resourceConflict  = "ResourceConflict"
+
+	volumeExpansionStarted = "VolumeExpansionStarted"
+	volumeExpansionDone    = "VolumeExpansionDone"
+	volumeExpansionFailed  = "VolumeExpansionFailed"
+
+	stsRecreated              = "StatefulSetRecreated"
+	stsImmutableFieldsChanged = "StatefulSetImmutableFieldsChanged"
+
+	paused        = "Paused"
+	resumed       = "Resumed"
+	dryRunPlanned = "DryRunPlanned"
 
 	// Event messages.
-	messageValidationFailed = "Failed validating Habitat"
-	messageCMCreated        = "Created peer IP ConfigMap"
-	messageCMUpdated        = "Updated peer IP ConfigMap"
-	messageCMFailed         = "Failed creating ConfigMap"
-	messagePeerIPAdded      = "Added peer IP to ConfigMap"
-	messagePeerIPUpdated    = "Updated peer IP in ConfigMap"
-	messagePeerIPRemoved    = "Removed peer IP from ConfigMap"
-	messageStsCreated       = "Created StatefulSet"
-	messageStsFailed        = "Failed creating StatefulSet"
+	messageValidationFailed  = "Failed validating Habitat"
+	messageCMCreated         = "Created peer IP ConfigMap"
+	messageCMUpdated         = "Updated peer IP ConfigMap"
+	messageCMFailed          = "Failed creating ConfigMap"
+	messagePeerIPAdded       = "Added peer IP to ConfigMap"
+	messagePeerIPUpdated     = "Updated peer IP in ConfigMap"
+	messagePeerIPRemoved     = "Removed peer IP from ConfigMap"
+	messageStsCreated        = "Created StatefulSet"
+	messageStsFailed         = "Failed creating StatefulSet"
+	messageUserConfigCreated = "Created user config Secret"
+	messageUserConfigUpdated = "Updated user config Secret"
+	messageUserConfigFailed  = "Failed rendering user config"
+	messageBindGraphFailed   = "Failed updating bind graph ConfigMap"
+	messageNwpCreated        = "Created NetworkPolicy"
+	messageNwpFailed         = "Failed reconciling NetworkPolicy"
+	messagePdbCreated        = "Created PodDisruptionBudget"
+	messagePdbFailed         = "Failed reconciling PodDisruptionBudget"
+	messageResourceConflict  = "%s %s already exists and doesn't belong to the Habitat, leaving it alone"
+	messagePaused            = "Paused reconciliation"
+	messageResumed           = "Resumed reconciliation"
+	messageDryRunPlanned     = "Dry run, would"
 )
 
 var ringRegexp *regexp.Regexp = regexp.MustCompile(ringKeyRegexp)
@@ -101,15 +133,36 @@ type HabitatController struct {
 	// delay, so that jobs in a crashing loop don't fill the queue.
 	queue workqueue.RateLimitingInterface
 
-	habInformer cache.SharedIndexInformer
-	stsInformer cache.SharedIndexInformer
-	cmInformer  cache.SharedIndexInformer
+	habInformer    cache.SharedIndexInformer
+	stsInformer    cache.SharedIndexInformer
+	cmInformer     cache.SharedIndexInformer
+	secretInformer cache.SharedIndexInformer
+	nwpInformer    cache.SharedIndexInformer
+	pdbInformer    cache.SharedIndexInformer
+	podInformer    cache.SharedIndexInformer
 
 	// cache.InformerSynced returns true if the store has been synced at least once.
-	habInformerSynced cache.InformerSynced
-	stsInformerSynced cache.InformerSynced
-	cmInformerSynced  cache.InformerSynced
-
+	habInformerSynced    cache.InformerSynced
+	stsInformerSynced    cache.InformerSynced
+	cmInformerSynced     cache.InformerSynced
+	secretInformerSynced cache.InformerSynced
+	nwpInformerSynced    cache.InformerSynced
+	pdbInformerSynced    cache.InformerSynced
+	podInformerSynced    cache.InformerSynced
+
+	// objects reads the Secrets and ConfigMaps referenced by Habitats.
+	objects objectGetter
+
+	// shards tracks the Habitats assigned to the controller, if they're
+	// sharded across several replicas of the operator.
+	shards *shardMembership
+
+	// settingsMu guards the settings of config which can be changed while the
+	// controller runs.
+	settingsMu sync.RWMutex
+
+	// events is the watch recording events to the API server.
+	events   watch.Interface
 	recorder record.EventRecorder
 }
 
@@ -120,6 +173,25 @@ type Config struct {
 	KubeInformerFactory    kubeinformers.SharedInformerFactory
 	HabitatInformerFactory habinformers.SharedInformerFactory
 	Namespace              string
+	// Namespaces maps each namespace watched by the controller to its informer
+	// factories, when it watches several namespaces. KubeInformerFactory,
+	// HabitatInformerFactory and Namespace are then ignored.
+	Namespaces map[string]NamespaceInformerFactories
+	// DryRun makes the controller log the changes it would make to the
+	// resources of all Habitats, instead of making them.
+	DryRun bool
+	// RateLimiter delays the retries of failed reconciles. It defaults to
+	// workqueue.DefaultControllerRateLimiter.
+	RateLimiter workqueue.RateLimiter
+	// Sharding, if set, makes the controller reconcile only its share of the
+	// Habitats.
+	Sharding *ShardingConfig
+	// HabitatDefaults are applied to the StatefulSets of new Habitats. They
+	// can be changed with SetHabitatDefaults.
+	HabitatDefaults HabitatDefaults
+	// FeatureGates enables or disables features of the controller. They can
+	// be changed with SetFeatureGates.
+	FeatureGates map[string]bool
 }
 
 func New(config Config, logger log.Logger) (*HabitatController, error) {
@@ -129,65 +201,98 @@ func New(config Config, logger log.Logger) (*HabitatController, error) {
 	if config.KubernetesClientset == nil {
 		return nil, errors.New("invalid controller config: no KubernetesClientset")
 	}
-	if config.KubeInformerFactory == nil {
-		return nil, errors.New("invalid controller config: no KubeInformerFactory")
+	if len(config.Namespaces) == 0 {
+		if config.KubeInformerFactory == nil {
+			return nil, errors.New("invalid controller config: no KubeInformerFactory")
+		}
+		if config.HabitatInformerFactory == nil {
+			return nil, errors.New("invalid controller config: no HabitatInformerFactory")
+		}
 	}
-	if config.HabitatInformerFactory == nil {
-		return nil, errors.New("invalid controller config: no HabitatInformerFactory")
+	for ns, f := range config.Namespaces {
+		if f.KubeInformerFactory == nil || f.HabitatInformerFactory == nil {
+			return nil, fmt.Errorf("invalid controller config: no informer factories for namespace %q", ns)
+		}
 	}
 	if logger == nil {
 		return nil, errors.New("invalid controller config: no logger")
 	}
+	if s := config.Sharding; s != nil {
+		if s.Namespace == "" || s.Identity == "" {
+			return nil, errors.New("invalid controller config: sharding needs a namespace and an identity")
+		}
+		// Leases record their duration in whole seconds.
+		if s.LeaseDuration < time.Second {
+			return nil, errors.New("invalid controller config: sharding needs a lease duration of at least a second")
+		}
+	}
+
+	if err := ValidateFeatureGates(config.FeatureGates); err != nil {
+		return nil, fmt.Errorf("invalid controller config: %v", err)
+	}
 
 	// Set up event broadcasting.
 	habscheme.AddToScheme(scheme.Scheme)
 	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: config.KubernetesClientset.CoreV1().Events("")})
+	events := eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: config.KubernetesClientset.CoreV1().Events("")})
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: controllerAgentName})
 
+	rateLimiter := config.RateLimiter
+	if rateLimiter == nil {
+		rateLimiter = workqueue.DefaultControllerRateLimiter()
+	}
+
 	hc := &HabitatController{
 		config:   config,
 		logger:   logger,
-		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Habitats"),
+		queue:    workqueue.NewNamedRateLimitingQueue(rateLimiter, "Habitats"),
+		objects:  clientObjectGetter{clientset: config.KubernetesClientset},
 		recorder: recorder,
+		events:   events,
+	}
+
+	if config.Sharding != nil {
+		hc.shards = newShardMembership(*config.Sharding, config.KubernetesClientset, logger, hc.enqueueAll)
+	}
+
+	// The informers are registered with the factories, and their indexers
+	// added, before the factories are started.
+	if err := hc.cacheHabitats(); err != nil {
+		return nil, err
+	}
+	hc.cacheStatefulSets()
+	hc.cacheConfigMaps()
+	hc.cacheSecrets()
+	hc.cacheNetworkPolicies()
+	hc.cachePodDisruptionBudgets()
+	if err := hc.cachePods(); err != nil {
+		return nil, err
 	}
 
 	return hc, nil
 }
 
-// Run starts a Habitat resource controller.
+// Run starts a Habitat resource controller. Its informers are run by the
+// informer factories of its config, which must be started once the controller
+// is created.
 func (hc *HabitatController) Run(ctx context.Context, workers int) error {
 	level.Info(hc.logger).Log("msg", "Watching Habitat objects")
 
 	var wg sync.WaitGroup
-	wg.Add(4 + workers)
-
-	hc.cacheHabitats()
-	hc.cacheStatefulSets()
-	hc.cacheConfigMaps()
-	hc.watchPods(ctx, &wg)
-
-	go func() {
-		hc.habInformer.Run(ctx.Done())
-		wg.Done()
-	}()
-
-	go func() {
-		hc.stsInformer.Run(ctx.Done())
-		wg.Done()
-	}()
-
-	go func() {
-		hc.cmInformer.Run(ctx.Done())
-		wg.Done()
-	}()
+	wg.Add(workers)
 
 	// Wait for caches to be synced before starting workers.
-	if !cache.WaitForCacheSync(ctx.Done(), hc.habInformerSynced, hc.stsInformerSynced, hc.cmInformerSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), hc.habInformerSynced, hc.stsInformerSynced, hc.cmInformerSynced, hc.secretInformerSynced, hc.nwpInformerSynced, hc.pdbInformerSynced, hc.podInformerSynced) {
 		return nil
 	}
 	level.Debug(hc.logger).Log("msg", "Caches synced")
 
+	if hc.shards != nil {
+		if err := hc.shards.start(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Start the synchronous queue consumers. If a worker exits because of a
 	// failed job, it will be restarted after a delay of 1 second.
 	for i := 0; i < workers; i++ {
@@ -207,12 +312,29 @@ func (hc *HabitatController) Run(ctx context.Context, workers int) error {
 	// Block until the WaitGroup counter is zero
 	wg.Wait()
 
+	// Stop recording events, as the controller may be replaced by a new one
+	// watching different namespaces.
+	hc.events.Stop()
+
 	// Err() contains the error, if any.
 	return ctx.Err()
 }
 
-func (hc *HabitatController) cacheHabitats() {
-	hc.habInformer = hc.config.HabitatInformerFactory.Habitat().V1beta1().Habitats().Informer()
+func (hc *HabitatController) cacheHabitats() error {
+	hc.habInformer = hc.habitatInformer()
+
+	// Index Habitats by the objects they reference, so that changes to those
+	// objects can be mapped back to the Habitats. The informer factory already
+	// indexes them by namespace.
+	err := hc.habInformer.AddIndexers(cache.Indexers{
+		secretRefIndex:    refIndexFunc(secretRefs),
+		configMapRefIndex: refIndexFunc(configMapRefs),
+		serviceGroupIndex: serviceGroupIndexFunc,
+		bindTargetIndex:   bindTargetIndexFunc,
+	})
+	if err != nil {
+		return err
+	}
 
 	hc.habInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    hc.handleHabAdd,
@@ -221,11 +343,17 @@ func (hc *HabitatController) cacheHabitats() {
 	})
 
 	hc.habInformerSynced = hc.habInformer.HasSynced
+
+	return nil
 }
 
 func (hc *HabitatController) cacheConfigMaps() {
-	hc.cmInformer = hc.config.KubeInformerFactory.Core().V1().ConfigMaps().Informer()
+	hc.cmInformer = hc.kubeInformer(func(f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ConfigMaps().Informer()
+	})
 
+	// The informer isn't filtered, as the ConfigMaps referenced by Habitats
+	// aren't labeled. Its handlers only enqueue the Habitats using a ConfigMap.
 	hc.cmInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    hc.handleCMAdd,
 		UpdateFunc: hc.handleCMUpdate,
@@ -235,30 +363,27 @@ func (hc *HabitatController) cacheConfigMaps() {
 	hc.cmInformerSynced = hc.cmInformer.HasSynced
 }
 
-func (hc *HabitatController) watchPods(ctx context.Context, wg *sync.WaitGroup) {
-	source := cache.NewFilteredListWatchFromClient(
-		hc.config.KubernetesClientset.CoreV1().RESTClient(),
-		"Pods",
-		hc.config.Namespace,
-		listOptions())
+func (hc *HabitatController) cachePods() error {
+	hc.podInformer = hc.podsInformer()
 
-	c := cache.NewSharedIndexInformer(
-		source,
-		&apiv1.Pod{},
-		resyncPeriod,
-		cache.Indexers{},
-	)
+	// Index Pods by their Habitat, so that the Pods of a Habitat can be read
+	// from the cache.
+	err := hc.podInformer.AddIndexers(cache.Indexers{
+		podHabitatIndex: podHabitatIndexFunc,
+	})
+	if err != nil {
+		return err
+	}
 
-	c.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	hc.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    hc.handlePodAdd,
 		UpdateFunc: hc.handlePodUpdate,
 		DeleteFunc: hc.handlePodDelete,
 	})
 
-	go func() {
-		c.Run(ctx.Done())
-		wg.Done()
-	}()
+	hc.podInformerSynced = hc.podInformer.HasSynced
+
+	return nil
 }
 
 func (hc *HabitatController) handleHabAdd(obj interface{}) {
@@ -269,6 +394,8 @@ func (hc *HabitatController) handleHabAdd(obj interface{}) {
 	}
 
 	hc.enqueue(h)
+	hc.enqueueConsumers(h)
+	hc.enqueueProducers(h)
 }
 
 func (hc *HabitatController) handleHabUpdate(oldObj, newObj interface{}) {
@@ -286,6 +413,16 @@ func (hc *HabitatController) handleHabUpdate(oldObj, newObj interface{}) {
 
 	if hc.habitatNeedsUpdate(oldHab, newHab) {
 		hc.enqueue(newHab)
+
+		// The service provided by the Habitat might have changed, so both the
+		// old and the new consumers need to be notified.
+		hc.enqueueConsumers(oldHab)
+		hc.enqueueConsumers(newHab)
+
+		// Likewise, the producers of both the old and the new binds need to
+		// know about their consumers.
+		hc.enqueueProducers(oldHab)
+		hc.enqueueProducers(newHab)
 	}
 }
 
@@ -297,8 +434,13 @@ func (hc *HabitatController) handleHabDelete(obj interface{}) {
 	}
 
 	hc.enqueue(h)
+	hc.enqueueConsumers(h)
+	hc.enqueueProducers(h)
 }
 
+// handleCM enqueues the Habitats affected by a change to a ConfigMap: those
+// referencing it and, for the ConfigMaps managed by the operator, the Habitat
+// owning it.
 func (hc *HabitatController) handleCM(obj interface{}) {
 	cm, ok := obj.(*apiv1.ConfigMap)
 	if !ok {
@@ -306,17 +448,11 @@ func (hc *HabitatController) handleCM(obj interface{}) {
 		return
 	}
 
-	cache.ListAll(hc.habInformer.GetStore(), labels.Everything(), func(obj interface{}) {
-		h, ok := obj.(*habv1beta1.Habitat)
-		if !ok {
-			level.Error(hc.logger).Log("msg", "Failed to type assert Habitat", "obj", obj)
-			return
-		}
+	hc.enqueueReferencing(configMapRefIndex, cm.Namespace, cm.Name)
 
-		if h.Namespace == cm.GetNamespace() {
-			hc.enqueue(h)
-		}
-	})
+	if isHabitatObject(&cm.ObjectMeta) {
+		hc.enqueueOwners(cm)
+	}
 }
 
 func (hc *HabitatController) handleCMAdd(obj interface{}) {
@@ -324,64 +460,100 @@ func (hc *HabitatController) handleCMAdd(obj interface{}) {
 }
 
 func (hc *HabitatController) handleCMUpdate(oldObj, newObj interface{}) {
-	hc.handleCM(newObj)
-}
-
-func (hc *HabitatController) handleCMDelete(obj interface{}) {
-	hc.handleCM(obj)
-}
+	oldCM, ok := oldObj.(*apiv1.ConfigMap)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert ConfigMap", "obj", oldObj)
+		return
+	}
 
-func (hc *HabitatController) handlePodAdd(obj interface{}) {
-	pod, ok := obj.(*apiv1.Pod)
+	newCM, ok := newObj.(*apiv1.ConfigMap)
 	if !ok {
-		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", obj)
+		level.Error(hc.logger).Log("msg", "Failed to type assert ConfigMap", "obj", newObj)
 		return
 	}
-	if isHabitatObject(&pod.ObjectMeta) {
-		h, err := hc.getHabitatFromLabeledResource(pod)
-		if err != nil {
-			level.Error(hc.logger).Log("msg", err)
-			return
-		}
 
-		hc.enqueue(h)
+	// Ignore resyncs.
+	if oldCM.ResourceVersion == newCM.ResourceVersion {
+		return
 	}
+
+	hc.handleCM(newObj)
 }
 
-func (hc *HabitatController) handlePodUpdate(oldObj, newObj interface{}) {
-	oldPod, ok1 := oldObj.(*apiv1.Pod)
-	if !ok1 {
-		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", oldObj)
-		return
+func (hc *HabitatController) handleCMDelete(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
 	}
 
-	newPod, ok2 := newObj.(*apiv1.Pod)
-	if !ok2 {
-		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", newObj)
+	cm, ok := obj.(*apiv1.ConfigMap)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert ConfigMap", "obj", obj)
 		return
 	}
 
-	if !hc.podNeedsUpdate(oldPod, newPod) {
+	// The peer and bind graph ConfigMaps are shared by all the Habitats of
+	// their namespace, and recreated by whichever of them is reconciled. Their
+	// owner, if any, might be gone.
+	if isHabitatObject(&cm.ObjectMeta) {
+		hc.enqueueNamespace(cm.Namespace)
 		return
 	}
 
-	h, err := hc.getHabitatFromLabeledResource(newPod)
-	if err != nil {
-		if hErr, ok := err.(keyNotFoundError); !ok {
-			level.Error(hc.logger).Log("msg", hErr, "key", hErr.key)
-			return
+	hc.enqueueReferencing(configMapRefIndex, cm.Namespace, cm.Name)
+}
+
+// enqueueOwners enqueues the Habitats listed in the owner references of obj.
+func (hc *HabitatController) enqueueOwners(obj metav1.Object) {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != habv1beta1.HabitatKind {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", obj.GetNamespace(), ref.Name)
+		o, exists, err := hc.habInformer.GetStore().GetByKey(key)
+		if err != nil {
+			level.Error(hc.logger).Log("msg", "Failed to look up owning Habitat", "err", err, "key", key)
+			continue
+		}
+		if !exists {
+			continue
 		}
 
-		// This only means the Pod and the Habitat watchers are not in sync.
-		level.Debug(hc.logger).Log("msg", "Habitat not found", "function", "handlePodUpdate")
+		h, ok := o.(*habv1beta1.Habitat)
+		if !ok || h.UID != ref.UID {
+			continue
+		}
 
+		hc.enqueue(h)
+	}
+}
+
+// enqueueNamespace enqueues all the Habitats of namespace.
+func (hc *HabitatController) enqueueNamespace(namespace string) {
+	objs, err := hc.habInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		level.Error(hc.logger).Log("msg", "Failed to look up Habitats of namespace", "err", err, "namespace", namespace)
 		return
 	}
 
-	hc.enqueue(h)
+	for _, obj := range objs {
+		h, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			level.Error(hc.logger).Log("msg", "Failed to type assert Habitat", "obj", obj)
+			continue
+		}
+
+		hc.enqueue(h)
+	}
 }
 
-func (hc *HabitatController) handlePodDelete(obj interface{}) {
+// handlePod enqueues the Habitat the Pod obj belongs to, according to its
+// labels.
+func (hc *HabitatController) handlePod(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
 	pod, ok := obj.(*apiv1.Pod)
 	if !ok {
 		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", obj)
@@ -394,39 +566,56 @@ func (hc *HabitatController) handlePodDelete(obj interface{}) {
 
 	h, err := hc.getHabitatFromLabeledResource(pod)
 	if err != nil {
-		if hErr, ok := err.(keyNotFoundError); !ok {
-			level.Error(hc.logger).Log("msg", hErr, "key", hErr.key)
+		if hErr, ok := err.(keyNotFoundError); ok {
+			// This only means the Pod and the Habitat watchers are not in sync.
+			level.Debug(hc.logger).Log("msg", "Habitat not found", "key", hErr.key, "pod", pod.Name)
 			return
 		}
 
-		// This only means the Pod and the Habitat watchers are not in sync.
-		level.Debug(hc.logger).Log("msg", "Habitat not found", "function", "handlePodDelete")
-
+		level.Error(hc.logger).Log("msg", err)
 		return
 	}
 
 	hc.enqueue(h)
 }
 
-func (hc *HabitatController) getRunningPods(namespace string) ([]apiv1.Pod, error) {
-	fs := fields.SelectorFromSet(fields.Set{
-		"status.phase": string(apiv1.PodRunning),
-	})
-	ls := fields.SelectorFromSet(fields.Set(map[string]string{
-		habv1beta1.HabitatLabel: "true",
-	}))
+func (hc *HabitatController) handlePodAdd(obj interface{}) {
+	hc.handlePod(obj)
+}
 
-	running := metav1.ListOptions{
-		FieldSelector: fs.String(),
-		LabelSelector: ls.String(),
+func (hc *HabitatController) handlePodUpdate(oldObj, newObj interface{}) {
+	oldPod, ok1 := oldObj.(*apiv1.Pod)
+	if !ok1 {
+		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", oldObj)
+		return
 	}
 
-	pods, err := hc.config.KubernetesClientset.CoreV1().Pods(namespace).List(running)
+	newPod, ok2 := newObj.(*apiv1.Pod)
+	if !ok2 {
+		level.Error(hc.logger).Log("msg", "Failed to type assert pod", "obj", newObj)
+		return
+	}
+
+	if !hc.podNeedsUpdate(oldPod, newPod) {
+		return
+	}
+
+	hc.handlePod(newPod)
+}
+
+func (hc *HabitatController) handlePodDelete(obj interface{}) {
+	hc.handlePod(obj)
+}
+
+// getRunningPods returns the running Pods of the Habitats in namespace, sorted
+// by name, which is the order in which the API server lists them.
+func (hc *HabitatController) getRunningPods(namespace string) ([]apiv1.Pod, error) {
+	objs, err := hc.podInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	return pods.Items, nil
+	return runningPods(objs), nil
 }
 
 func (hc *HabitatController) writeLeaderIP(cm *apiv1.ConfigMap, ip string) error {
@@ -541,9 +730,32 @@ func (hc *HabitatController) enqueue(hab *habv1beta1.Habitat) {
 		return
 	}
 
+	if !hc.owns(k) {
+		return
+	}
+
 	hc.queue.Add(k)
 }
 
+// enqueueAll enqueues all the Habitats, e.g. when the Habitats assigned to the
+// controller changed.
+func (hc *HabitatController) enqueueAll() {
+	for _, obj := range hc.habInformer.GetStore().List() {
+		h, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			level.Error(hc.logger).Log("msg", "Failed to type assert Habitat", "obj", obj)
+			continue
+		}
+
+		hc.enqueue(h)
+	}
+}
+
+// owns returns whether the controller reconciles the Habitat with the key.
+func (hc *HabitatController) owns(key string) bool {
+	return hc.shards == nil || hc.shards.owns(key)
+}
+
 func (hc *HabitatController) worker() {
 	for hc.processNextItem() {
 	}
@@ -569,6 +781,24 @@ func (hc *HabitatController) processNextItem() bool {
 
 	defer hc.queue.Done(key)
 
+	// The Habitat was reassigned to another replica since it was enqueued. The
+	// bind graph of its namespace may still be assigned to this one.
+	if !hc.owns(k) {
+		if !hc.config.DryRun {
+			if err := hc.syncBindGraph(k); err != nil {
+				level.Error(hc.logger).Log("msg", "bind graph could not be synced, requeueing", "err", err, "obj", k)
+
+				hc.queue.AddRateLimited(k)
+
+				return true
+			}
+		}
+
+		hc.queue.Forget(k)
+
+		return true
+	}
+
 	if err := hc.conform(k); err != nil {
 		level.Error(hc.logger).Log("msg", "Habitat could not be synced, requeueing", "err", err, "obj", k)
 
@@ -594,28 +824,131 @@ func (hc *HabitatController) conform(key string) error {
 	if !exists {
 		// The Habitat was deleted.
 		level.Info(hc.logger).Log("msg", "deleted Habitat", "key", key)
-		return nil
+
+		if hc.config.DryRun {
+			return nil
+		}
+
+		// Remove it from the bind graph of its namespace.
+		return hc.syncBindGraph(key)
 	}
 
 	// The Habitat was either created or updated.
-	h, ok := obj.(*habv1beta1.Habitat)
+	cached, ok := obj.(*habv1beta1.Habitat)
 	if !ok {
 		return fmt.Errorf("unknown event type")
 	}
 
-	level.Debug(hc.logger).Log("function", "handle Habitat Creation", "msg", h.ObjectMeta.SelfLink)
+	level.Debug(hc.logger).Log("function", "handle Habitat Creation", "msg", cached.ObjectMeta.SelfLink)
 
 	// Validate object.
-	if err := validateCustomObject(*h); err != nil {
-		hc.recorder.Event(h, apiv1.EventTypeWarning, validationFailed, messageValidationFailed)
+	if err := validateCustomObject(*cached); err != nil {
+		hc.recorder.Event(cached, apiv1.EventTypeWarning, validationFailed, messageValidationFailed)
 		return err
 	}
 
 	level.Debug(hc.logger).Log("msg", "validated object")
 
+	if hc.dryRun(cached) {
+		return hc.plan(cached)
+	}
+
+	// Objects in the cache must not be modified, so the status is updated on a
+	// copy.
+	h := cached.DeepCopy()
+
+	if err := hc.reconcile(h); err != nil {
+		// Record the progress made so far, but report the original error.
+		if statusErr := hc.updateStatus(h, cached.Status); statusErr != nil {
+			level.Error(hc.logger).Log("msg", "Habitat status could not be updated", "err", statusErr, "obj", key)
+		}
+
+		return err
+	}
+
+	return hc.updateStatus(h, cached.Status)
+}
+
+// reconcile creates or updates the resources belonging to the Habitat h, and
+// records the outcome in its status.
+func (hc *HabitatController) reconcile(h *habv1beta1.Habitat) error {
+	// While paused, only the Paused condition of the Habitat is updated, and
+	// nothing else is changed on its behalf.
+	if hc.reconcilePaused(h) {
+		level.Info(hc.logger).Log("msg", "reconciliation is paused", "name", h.Name)
+		return nil
+	}
+
+	bindsReady, err := hc.reconcileBinds(h)
+	if err != nil {
+		return err
+	}
+
+	g, err := newBindGraph(hc.habInformer.GetIndexer(), h.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := hc.handleBindGraphConfigMap(h.Namespace, g); err != nil {
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, bindGraphFailed, "%s: %s", messageBindGraphFailed, err)
+		return err
+	}
+
+	deploy := hc.reconcileBindCycle(h, g)
+
+	// Services whose binds form a cycle can't start, since each of them would
+	// wait for the others, so the StatefulSet is neither created nor updated.
+	if !deploy {
+		level.Info(hc.logger).Log("msg", "binds form a cycle, not deploying", "name", h.Name)
+		return nil
+	}
+
+	// Delay the creation of the StatefulSet until the services the Habitat
+	// binds to are running. Once it exists, it is always kept up-to-date.
+	if !bindsReady {
+		_, exists, err := hc.stsInformer.GetStore().GetByKey(fmt.Sprintf("%s/%s", h.Namespace, h.Name))
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			level.Info(hc.logger).Log("msg", "waiting for binds before creating StatefulSet", "name", h.Name)
+			return nil
+		}
+	}
+
+	if err := hc.handleNetworkPolicy(h); err != nil {
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, nwpFailed, "%s: %s", messageNwpFailed, err)
+		return err
+	}
+
+	// Existing PodDisruptionBudgets are left as they are while the feature is
+	// disabled.
+	if hc.featureEnabled(FeatureGatePodDisruptionBudgets) {
+		if err := hc.handlePodDisruptionBudget(h); err != nil {
+			hc.recorder.Eventf(h, apiv1.EventTypeWarning, pdbFailed, "%s: %s", messagePdbFailed, err)
+			return err
+		}
+	}
+
+	// Render the inline config, if any, before the StatefulSet mounts it.
+	if err := hc.handleUserConfig(h); err != nil {
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, userConfigFailed, "%s: %s", messageUserConfigFailed, err)
+		return err
+	}
+
 	newSts, err := hc.newStatefulSet(h)
 	if err != nil {
-		hc.recorder.Eventf(h, apiv1.EventTypeWarning, stsFailed, fmt.Sprintf("%s: %s", messageStsFailed, err))
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, stsFailed, "%s: %s", messageStsFailed, err)
+		return err
+	}
+
+	if err := hc.createDataSourceClaims(h, newSts); err != nil {
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, stsFailed, "%s: %s", messageStsFailed, err)
+		return err
+	}
+
+	if err := setLastApplied(newSts); err != nil {
 		return err
 	}
 
@@ -629,8 +962,21 @@ func (hc *HabitatController) conform(key string) error {
 				return err
 			}
 
-			// Update the StatefulSet
-			updatedSts, err := hc.config.KubernetesClientset.AppsV1().StatefulSets(h.Namespace).Update(newSts)
+			// Claim templates can't be updated, so resized volumes are applied
+			// by recreating the StatefulSet, once the rest of the spec has been
+			// updated.
+			recreate, err := hc.reconcileVolumeExpansion(h, oldSts, newSts)
+			if err != nil {
+				return err
+			}
+
+			// The other immutable fields are applied by recreating the
+			// StatefulSet too, when its Pods can be adopted by the new one.
+			recreate = hc.reconcileImmutableChanges(h, oldSts, newSts, recreate)
+			preserveImmutableFields(oldSts, newSts)
+
+			// Update the fields of the StatefulSet set by the operator.
+			updatedSts, err := hc.applyStatefulSet(oldSts, newSts)
 			if err != nil {
 				return err
 			}
@@ -641,13 +987,19 @@ func (hc *HabitatController) conform(key string) error {
 			// When the bug is fixed and the workaround is removed, make
 			// sure to change UpdateStrategy to RollingUpdate as OnDelete
 			// will break updates to deployments.
-			if killAllPods := !reflect.DeepEqual(oldSts.Spec.Template, updatedSts.Spec.Template); killAllPods {
+			if killAllPods := templateChanged(oldSts, updatedSts); killAllPods {
 				level.Info(hc.logger).Log("msg", "deleting pods under StatefulSet", "name", updatedSts.Name)
 				if err := hc.deleteStatefulSetPods(updatedSts); err != nil {
 					return err
 				}
 			}
 
+			if recreate {
+				if err := hc.orphanStatefulSet(updatedSts); err != nil {
+					return err
+				}
+			}
+
 			level.Debug(hc.logger).Log("msg", "StatefulSet already existed", "name", updatedSts.Name)
 		} else {
 			hc.recorder.Event(h, apiv1.EventTypeWarning, stsFailed, messageStsFailed)
@@ -661,7 +1013,7 @@ func (hc *HabitatController) conform(key string) error {
 
 	// Handle creation/updating of peer IP ConfigMap.
 	if err := hc.handleConfigMap(h); err != nil {
-		hc.recorder.Eventf(h, apiv1.EventTypeWarning, cmFailed, fmt.Sprintf("%s: %s", messageCMFailed, err))
+		hc.recorder.Eventf(h, apiv1.EventTypeWarning, cmFailed, "%s: %s", messageCMFailed, err)
 		return err
 	}
 
@@ -669,7 +1021,9 @@ func (hc *HabitatController) conform(key string) error {
 }
 
 func (hc *HabitatController) habitatNeedsUpdate(oldHabitat, newHabitat *habv1beta1.Habitat) bool {
-	if reflect.DeepEqual(oldHabitat.Spec.V1beta2, newHabitat.Spec.V1beta2) {
+	if reflect.DeepEqual(oldHabitat.Spec.V1beta2, newHabitat.Spec.V1beta2) &&
+		hc.dryRun(oldHabitat) == hc.dryRun(newHabitat) &&
+		oldHabitat.Annotations[habv1beta1.RestartedAtAnnotation] == newHabitat.Annotations[habv1beta1.RestartedAtAnnotation] {
 		level.Debug(hc.logger).Log("msg", "Update ignored as it didn't change Habitat spec", "h", newHabitat)
 		return false
 	}
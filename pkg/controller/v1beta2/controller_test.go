@@ -16,12 +16,17 @@ package v1beta2
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 
 	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
 func TestHabitatKeyFromLabeledResource(t *testing.T) {
@@ -134,3 +139,86 @@ func TestNewConfigMap(t *testing.T) {
 		})
 	}
 }
+
+// newTestHandlerController returns a controller whose Habitat cache contains
+// habitats, to test which of them are enqueued by event handlers.
+func newTestHandlerController(t *testing.T, habitats ...*habv1beta1.Habitat) *HabitatController {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &habv1beta1.Habitat{}, 0, cache.Indexers{
+		configMapRefIndex:    refIndexFunc(configMapRefs),
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+
+	for _, h := range habitats {
+		if err := informer.GetIndexer().Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &HabitatController{
+		logger:      log.NewNopLogger(),
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Habitats"),
+		habInformer: informer,
+	}
+}
+
+// queuedKeys returns the sorted keys in the queue of hc.
+func queuedKeys(hc *HabitatController) []string {
+	var keys []string
+	for hc.queue.Len() > 0 {
+		k, _ := hc.queue.Get()
+		keys = append(keys, k.(string))
+		hc.queue.Done(k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestHandleCM(t *testing.T) {
+	owner := newTestHabitat("default", "owner", "redis", nil)
+	owner.UID = "owner-uid"
+	user := newTestHabitat("default", "user", "redis", nil)
+	user.Spec.V1beta2.Service.ConfigMapRef = &apiv1.LocalObjectReference{Name: "redis-config"}
+	other := newTestHabitat("default", "other", "redis", nil)
+
+	peerCM := newConfigMap("", owner)
+	userCM := &apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "redis-config", Namespace: "default"}}
+	unrelatedCM := &apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}}
+
+	tests := []struct {
+		name   string
+		handle func(hc *HabitatController)
+		want   []string
+	}{
+		{
+			name:   "peer ConfigMap updated",
+			handle: func(hc *HabitatController) { hc.handleCM(peerCM) },
+			want:   []string{"default/owner"},
+		},
+		{
+			name:   "peer ConfigMap deleted",
+			handle: func(hc *HabitatController) { hc.handleCMDelete(cache.DeletedFinalStateUnknown{Obj: peerCM}) },
+			want:   []string{"default/other", "default/owner", "default/user"},
+		},
+		{
+			name:   "referenced ConfigMap updated",
+			handle: func(hc *HabitatController) { hc.handleCM(userCM) },
+			want:   []string{"default/user"},
+		},
+		{
+			name:   "unrelated ConfigMap updated",
+			handle: func(hc *HabitatController) { hc.handleCM(unrelatedCM) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := newTestHandlerController(t, owner, user, other)
+			tt.handle(hc)
+
+			if got := queuedKeys(hc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enqueued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// habitatDefaultsAnnotation records on a StatefulSet, and on its Pods, the
+// defaults it was created with.
+const habitatDefaultsAnnotation = "operator.habitat.sh/habitat-defaults"
+
+// Feature gates.
+const (
+	// FeatureGatePodDisruptionBudgets makes the controller manage the
+	// PodDisruptionBudgets of Habitats.
+	FeatureGatePodDisruptionBudgets = "PodDisruptionBudgets"
+	// FeatureGateVolumeExpansion makes the controller expand the persistent
+	// volumes of Habitats whose requested size grows.
+	FeatureGateVolumeExpansion = "VolumeExpansion"
+)
+
+// defaultFeatureGates are the feature gates, and whether they're enabled by
+// default.
+var defaultFeatureGates = map[string]bool{
+	FeatureGatePodDisruptionBudgets: true,
+	FeatureGateVolumeExpansion:      true,
+}
+
+// ValidateFeatureGates returns an error if gates contains unknown feature
+// gates.
+func ValidateFeatureGates(gates map[string]bool) error {
+	for name := range gates {
+		if _, ok := defaultFeatureGates[name]; ok {
+			continue
+		}
+
+		var known []string
+		for name := range defaultFeatureGates {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+
+		return fmt.Errorf("unknown feature gate %q, expected one of %s", name, strings.Join(known, ", "))
+	}
+
+	return nil
+}
+
+// HabitatDefaults are the settings of the StatefulSets of Habitats which don't
+// set them in their spec. They're applied to the StatefulSets created after the
+// defaults are set, so that changing them doesn't restart the Pods of existing
+// Habitats.
+type HabitatDefaults struct {
+	// FilesInitImage is the image of the init container copying the files of
+	// Habitats without a filesInitImage. It defaults to the image of the
+	// Habitat.
+	FilesInitImage string `json:"filesInitImage,omitempty"`
+	// Resources are the compute resources of the container of the service.
+	Resources apiv1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+func (d HabitatDefaults) isZero() bool {
+	return reflect.DeepEqual(d, HabitatDefaults{})
+}
+
+// SetHabitatDefaults sets the defaults applied to the StatefulSets created
+// from now on.
+func (hc *HabitatController) SetHabitatDefaults(d HabitatDefaults) {
+	hc.settingsMu.Lock()
+	defer hc.settingsMu.Unlock()
+
+	hc.config.HabitatDefaults = d
+}
+
+// SetFeatureGates enables or disables features of the controller, starting
+// with the next reconcile of each Habitat. Gates missing from gates keep their
+// default.
+func (hc *HabitatController) SetFeatureGates(gates map[string]bool) {
+	hc.settingsMu.Lock()
+	defer hc.settingsMu.Unlock()
+
+	hc.config.FeatureGates = gates
+}
+
+// featureEnabled returns whether the feature gate name is enabled.
+func (hc *HabitatController) featureEnabled(name string) bool {
+	hc.settingsMu.RLock()
+	defer hc.settingsMu.RUnlock()
+
+	if enabled, ok := hc.config.FeatureGates[name]; ok {
+		return enabled
+	}
+
+	return defaultFeatureGates[name]
+}
+
+// habitatDefaults returns the defaults of the StatefulSet of the Habitat h:
+// the ones its StatefulSet was created with, if it exists, or else the current
+// ones.
+func (hc *HabitatController) habitatDefaults(h *habv1beta1.Habitat) (HabitatDefaults, error) {
+	if hc.stsInformer != nil {
+		obj, exists, err := hc.stsInformer.GetStore().GetByKey(habitatKey(h))
+		if err != nil {
+			return HabitatDefaults{}, err
+		}
+
+		if exists {
+			sts, ok := obj.(*appsv1.StatefulSet)
+			if !ok {
+				return HabitatDefaults{}, fmt.Errorf("unknown object type in StatefulSet cache: %v", obj)
+			}
+
+			return recordedHabitatDefaults(sts)
+		}
+	}
+
+	// While a StatefulSet is recreated to change its immutable fields, the
+	// Pods it orphaned keep the defaults it was created with, which are
+	// carried over to the replacement.
+	if hc.podInformer != nil {
+		objs, err := hc.podInformer.GetIndexer().ByIndex(podHabitatIndex, habitatKey(h))
+		if err != nil {
+			return HabitatDefaults{}, err
+		}
+
+		if len(objs) > 0 {
+			pod, ok := objs[0].(*apiv1.Pod)
+			if !ok {
+				return HabitatDefaults{}, fmt.Errorf("unknown object type in Pod cache: %v", objs[0])
+			}
+
+			return recordedHabitatDefaults(pod)
+		}
+	}
+
+	hc.settingsMu.RLock()
+	defer hc.settingsMu.RUnlock()
+
+	return hc.config.HabitatDefaults, nil
+}
+
+// recordedHabitatDefaults returns the defaults the StatefulSet, or the Pod,
+// obj was created with. Those created without defaults have no annotation.
+func recordedHabitatDefaults(obj metav1.Object) (HabitatDefaults, error) {
+	var d HabitatDefaults
+
+	data, ok := obj.GetAnnotations()[habitatDefaultsAnnotation]
+	if !ok {
+		return d, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return d, fmt.Errorf("invalid %s annotation on %s: %v", habitatDefaultsAnnotation, obj.GetName(), err)
+	}
+
+	return d, nil
+}
+
+// recordHabitatDefaults records on the StatefulSet sts, and on its Pod
+// template, the defaults d it's created with.
+func recordHabitatDefaults(sts *appsv1.StatefulSet, d HabitatDefaults) error {
+	if d.isZero() {
+		return nil
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range []*metav1.ObjectMeta{&sts.ObjectMeta, &sts.Spec.Template.ObjectMeta} {
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[habitatDefaultsAnnotation] = string(data)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestDefaultsHabitat(name string) *habv1beta1.Habitat {
+	h := newTestHabitat("default", name, "redis", nil)
+	h.Spec.V1beta2.Count = 1
+	h.Spec.V1beta2.Image = "habitat/redis-hab"
+	h.Spec.V1beta2.Service.FilesSources = []apiv1.VolumeProjection{
+		{ConfigMap: &apiv1.ConfigMapProjection{LocalObjectReference: apiv1.LocalObjectReference{Name: "files"}}},
+	}
+
+	return h
+}
+
+func TestHabitatDefaults(t *testing.T) {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &appsv1.StatefulSet{}, 0, cache.Indexers{})
+	hc := &HabitatController{
+		logger:      log.NewNopLogger(),
+		objects:     newStaticObjectGetter(nil, nil),
+		stsInformer: informer,
+	}
+
+	// The StatefulSet of an existing Habitat, created without defaults.
+	existing := newTestDefaultsHabitat("existing")
+	oldSts, err := hc.newStatefulSet(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSts.Namespace = existing.Namespace
+	if err := informer.GetIndexer().Add(oldSts); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := HabitatDefaults{
+		FilesInitImage: "busybox",
+		Resources: apiv1.ResourceRequirements{
+			Requests: apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse("64Mi")},
+		},
+	}
+	hc.SetHabitatDefaults(defaults)
+
+	// New Habitats get the defaults.
+	sts, err := hc.newStatefulSet(newTestDefaultsHabitat("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tSpec := sts.Spec.Template.Spec
+	if got := tSpec.InitContainers[0].Image; got != "busybox" {
+		t.Errorf("init container image = %q, want busybox", got)
+	}
+	if got := tSpec.Containers[0].Resources.Requests[apiv1.ResourceMemory]; got.String() != "64Mi" {
+		t.Errorf("memory request = %s, want 64Mi", got.String())
+	}
+	if recorded, err := recordedHabitatDefaults(sts); err != nil || recorded.FilesInitImage != "busybox" {
+		t.Errorf("recorded defaults = %+v, %v, want the current defaults", recorded, err)
+	}
+
+	// The spec of the Habitat takes precedence.
+	h := newTestDefaultsHabitat("own-image")
+	h.Spec.V1beta2.Service.FilesInitImage = strToPtr("alpine")
+	sts, err = hc.newStatefulSet(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sts.Spec.Template.Spec.InitContainers[0].Image; got != "alpine" {
+		t.Errorf("init container image = %q, want alpine", got)
+	}
+
+	// Existing Habitats keep the defaults they were created with, so that their
+	// Pods aren't restarted.
+	sts, err = hc.newStatefulSet(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if templateChanged(oldSts, sts) {
+		t.Error("templateChanged() = true for an existing Habitat after changing the defaults, want false")
+	}
+	if _, ok := sts.Annotations[habitatDefaultsAnnotation]; ok {
+		t.Errorf("StatefulSet created without defaults has the %s annotation", habitatDefaultsAnnotation)
+	}
+}
+
+func TestHabitatDefaultsRecreatedStatefulSet(t *testing.T) {
+	podInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &apiv1.Pod{}, 0, cache.Indexers{
+		podHabitatIndex: podHabitatIndexFunc,
+	})
+	hc := &HabitatController{
+		logger:      log.NewNopLogger(),
+		objects:     newStaticObjectGetter(nil, nil),
+		stsInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &appsv1.StatefulSet{}, 0, cache.Indexers{}),
+		podInformer: podInformer,
+	}
+	hc.SetHabitatDefaults(HabitatDefaults{FilesInitImage: "busybox"})
+
+	h := newTestDefaultsHabitat("recreated")
+	oldSts, err := hc.newStatefulSet(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The StatefulSet was deleted to be recreated, orphaning its Pod.
+	pod := &apiv1.Pod{ObjectMeta: oldSts.Spec.Template.ObjectMeta}
+	pod.Name = h.Name + "-0"
+	pod.Namespace = h.Namespace
+	if err := podInformer.GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	hc.SetHabitatDefaults(HabitatDefaults{FilesInitImage: "alpine"})
+
+	sts, err := hc.newStatefulSet(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if templateChanged(oldSts, sts) {
+		t.Error("templateChanged() = true for a recreated StatefulSet after changing the defaults, want false")
+	}
+	if got, want := sts.Annotations[habitatDefaultsAnnotation], oldSts.Annotations[habitatDefaultsAnnotation]; got != want {
+		t.Errorf("%s annotation = %q, want %q", habitatDefaultsAnnotation, got, want)
+	}
+}
+
+func TestFeatureGates(t *testing.T) {
+	hc := &HabitatController{}
+
+	if !hc.featureEnabled(FeatureGateVolumeExpansion) {
+		t.Errorf("%s disabled by default, want enabled", FeatureGateVolumeExpansion)
+	}
+
+	hc.SetFeatureGates(map[string]bool{FeatureGateVolumeExpansion: false})
+	if hc.featureEnabled(FeatureGateVolumeExpansion) {
+		t.Errorf("%s enabled after disabling it", FeatureGateVolumeExpansion)
+	}
+	if !hc.featureEnabled(FeatureGatePodDisruptionBudgets) {
+		t.Errorf("%s disabled, want its default", FeatureGatePodDisruptionBudgets)
+	}
+
+	if err := ValidateFeatureGates(map[string]bool{"Unknown": true}); err == nil {
+		t.Error("ValidateFeatureGates() with an unknown gate returned no error")
+	}
+}
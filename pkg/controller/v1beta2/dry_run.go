@@ -0,0 +1,387 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// dryRun returns true if the changes to the resources of the Habitat h are
+// only to be planned, not made.
+func (hc *HabitatController) dryRun(h *habv1beta1.Habitat) bool {
+	return hc.config.DryRun || h.Annotations[habv1beta1.DryRunAnnotation] == "true"
+}
+
+// plan logs the changes reconcile would make to the resources of the Habitat
+// h, and records them in an event, without making them. The bind graph
+// ConfigMap is shared by all the Habitats of a namespace, so it isn't
+// planned, and neither are Services, which the operator doesn't manage.
+func (hc *HabitatController) plan(h *habv1beta1.Habitat) error {
+	// Nothing would be changed while reconcile holds the Habitat back, which
+	// is checked without updating its status.
+	if h.Spec.V1beta2.Paused {
+		level.Debug(hc.logger).Log("msg", "dry run, reconciliation is paused", "name", h.Name)
+		return nil
+	}
+
+	g, err := newBindGraph(hc.habInformer.GetIndexer(), h.Namespace)
+	if err != nil {
+		return err
+	}
+	if g.cycleOf(habitatKey(h)) != nil {
+		level.Info(hc.logger).Log("msg", "dry run, binds form a cycle, not deploying", "name", h.Name)
+		return nil
+	}
+
+	_, waiting, err := hc.pendingBinds(h)
+	if err != nil {
+		return err
+	}
+	if len(waiting) > 0 {
+		_, exists, err := hc.stsInformer.GetStore().GetByKey(habitatKey(h))
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			level.Info(hc.logger).Log("msg", "dry run, waiting for binds before creating StatefulSet", "name", h.Name)
+			return nil
+		}
+	}
+
+	var changes []string
+
+	if c, err := hc.planNetworkPolicy(h); err != nil {
+		return err
+	} else if c != "" {
+		changes = append(changes, c)
+	}
+
+	if hc.featureEnabled(FeatureGatePodDisruptionBudgets) {
+		if c, err := hc.planPodDisruptionBudget(h); err != nil {
+			return err
+		} else if c != "" {
+			changes = append(changes, c)
+		}
+	}
+
+	if c, err := hc.planUserConfigSecret(h); err != nil {
+		return err
+	} else if c != "" {
+		changes = append(changes, c)
+	}
+
+	newSts, err := hc.newStatefulSet(h)
+	if err != nil {
+		// The StatefulSet might depend on resources which would have been
+		// created by now, such as the user config Secret.
+		changes = append(changes, fmt.Sprintf("fail to create StatefulSet %s: %v", h.Name, err))
+	} else {
+		// Claims aren't cached, so the ones which already exist can't be
+		// told apart.
+		claims, err := hc.dataSourceClaims(h, newSts)
+		if err != nil {
+			return err
+		}
+		for _, c := range claims {
+			changes = append(changes, fmt.Sprintf("create PersistentVolumeClaim %s from %s %s, unless it exists", c.claim.Name, c.dataSource.Kind, c.dataSource.Name))
+		}
+
+		obj, exists, err := hc.stsInformer.GetStore().GetByKey(habitatKey(h))
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			changes = append(changes, fmt.Sprintf("create StatefulSet %s", newSts.Name))
+		} else {
+			oldSts, ok := obj.(*appsv1.StatefulSet)
+			if !ok {
+				return fmt.Errorf("unknown object type in StatefulSet cache: %v", obj)
+			}
+
+			stsChanges, err := planStatefulSet(oldSts, newSts)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, stsChanges...)
+		}
+	}
+
+	runningPods, err := hc.getRunningPods(h.Namespace)
+	if err != nil {
+		return err
+	}
+
+	cm, err := hc.findConfigMapInCache(newConfigMap("", h))
+	if err != nil {
+		if _, ok := err.(keyNotFoundError); !ok {
+			return err
+		}
+		cm = nil
+	}
+
+	if c := planPeerConfigMap(cm, runningPods); c != "" {
+		changes = append(changes, c)
+	}
+
+	if len(changes) == 0 {
+		level.Debug(hc.logger).Log("msg", "dry run, no changes planned", "name", h.Name)
+		return nil
+	}
+
+	for _, c := range changes {
+		level.Info(hc.logger).Log("msg", "dry run, planned change", "name", h.Name, "change", c)
+	}
+
+	// The diffs can be long, so they are only logged.
+	summary := make([]string, 0, len(changes))
+	for _, c := range changes {
+		summary = append(summary, strings.SplitN(c, ":", 2)[0])
+	}
+	hc.recorder.Eventf(h, apiv1.EventTypeNormal, dryRunPlanned, "%s: %s", messageDryRunPlanned, strings.Join(summary, ", "))
+
+	return nil
+}
+
+// planNetworkPolicy returns the change handleNetworkPolicy would make to the
+// NetworkPolicy of the Habitat h, if any.
+func (hc *HabitatController) planNetworkPolicy(h *habv1beta1.Habitat) (string, error) {
+	obj, exists, err := hc.nwpInformer.GetStore().GetByKey(habitatKey(h))
+	if err != nil {
+		return "", err
+	}
+
+	var np *networkingv1.NetworkPolicy
+	if exists {
+		var ok bool
+		if np, ok = obj.(*networkingv1.NetworkPolicy); !ok {
+			return "", fmt.Errorf("unknown object type in NetworkPolicy cache: %v", obj)
+		}
+
+		if !ownedByHabitat(np, h) {
+			return "", nil
+		}
+	}
+
+	if h.Spec.V1beta2.NetworkPolicy == nil {
+		if !exists {
+			return "", nil
+		}
+
+		return fmt.Sprintf("delete NetworkPolicy %s", h.Name), nil
+	}
+
+	consumers, err := sameNamespaceConsumers(hc.habInformer.GetIndexer(), h)
+	if err != nil {
+		return "", err
+	}
+
+	newNP := newNetworkPolicy(h, consumers)
+	if err := setLastApplied(newNP); err != nil {
+		return "", err
+	}
+
+	if !exists {
+		return fmt.Sprintf("create NetworkPolicy %s", newNP.Name), nil
+	}
+
+	patch, err := applyPatch(newNP, np, networkingv1.NetworkPolicy{})
+	if err != nil || patch == nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("update NetworkPolicy %s: %s", np.Name, patch), nil
+}
+
+// planPodDisruptionBudget returns the change handlePodDisruptionBudget would
+// make to the PodDisruptionBudget of the Habitat h, if any.
+func (hc *HabitatController) planPodDisruptionBudget(h *habv1beta1.Habitat) (string, error) {
+	obj, exists, err := hc.pdbInformer.GetStore().GetByKey(habitatKey(h))
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		if !pdbEnabled(h) {
+			return "", nil
+		}
+
+		return fmt.Sprintf("create PodDisruptionBudget %s", h.Name), nil
+	}
+
+	pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		return "", fmt.Errorf("unknown object type in PodDisruptionBudget cache: %v", obj)
+	}
+
+	if !ownedByHabitat(pdb, h) {
+		return "", nil
+	}
+
+	if !pdbEnabled(h) {
+		return fmt.Sprintf("delete PodDisruptionBudget %s", pdb.Name), nil
+	}
+
+	if reflect.DeepEqual(pdb.Spec, newPodDisruptionBudget(h).Spec) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("recreate PodDisruptionBudget %s", pdb.Name), nil
+}
+
+// planUserConfigSecret returns the change handleUserConfig would make to the
+// rendered config Secret of the Habitat h, if any. The contents of the Secret
+// aren't included, as they might contain credentials.
+func (hc *HabitatController) planUserConfigSecret(h *habv1beta1.Habitat) (string, error) {
+	name := userConfigSecretName(h)
+
+	obj, exists, err := hc.secretInformer.GetStore().GetByKey(fmt.Sprintf("%s/%s", h.Namespace, name))
+	if err != nil {
+		return "", err
+	}
+
+	var oldSecret *apiv1.Secret
+	if exists {
+		var ok bool
+		if oldSecret, ok = obj.(*apiv1.Secret); !ok {
+			return "", fmt.Errorf("unknown object type in Secret cache: %v", obj)
+		}
+	}
+
+	if !needsRenderedUserConfig(h.Spec.V1beta2.Service) {
+		if oldSecret == nil || !ownedByHabitat(oldSecret, h) {
+			return "", nil
+		}
+
+		return fmt.Sprintf("delete Secret %s", name), nil
+	}
+
+	if oldSecret != nil && !ownedByHabitat(oldSecret, h) {
+		return fmt.Sprintf("fail to render Secret %s: %v", name, errUserConfigSecretNotOwned(oldSecret)), nil
+	}
+
+	newSecret, err := hc.renderUserConfigSecret(h)
+	if err != nil {
+		return fmt.Sprintf("fail to render Secret %s: %v", name, err), nil
+	}
+
+	if oldSecret == nil {
+		return fmt.Sprintf("create Secret %s", name), nil
+	}
+
+	if reflect.DeepEqual(oldSecret.Data, newSecret.Data) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("update Secret %s", name), nil
+}
+
+// planStatefulSet returns the changes reconcile would make to oldSts to match
+// the desired StatefulSet newSts, including the patch applied to it.
+func planStatefulSet(oldSts, newSts *appsv1.StatefulSet) ([]string, error) {
+	var changes []string
+
+	newSts = newSts.DeepCopy()
+
+	immutable := immutableChanges(oldSts, newSts)
+	for _, r := range claimResizes(oldSts, newSts) {
+		immutable = append(immutable, fmt.Sprintf("the size of volume %q from %s to %s", r.template.Name, r.oldSize.String(), r.newSize.String()))
+	}
+	preserveImmutableFields(oldSts, newSts)
+
+	patch, err := applyPatch(newSts, oldSts, appsv1.StatefulSet{})
+	if err != nil {
+		return nil, err
+	}
+
+	if patch != nil {
+		changes = append(changes, fmt.Sprintf("update StatefulSet %s: %s", oldSts.Name, patch))
+
+		updatedSts, err := patchedStatefulSet(oldSts, patch)
+		if err != nil {
+			return nil, err
+		}
+
+		if templateChanged(oldSts, updatedSts) {
+			changes = append(changes, fmt.Sprintf("delete the %d Pods of StatefulSet %s", oldSts.Status.Replicas, oldSts.Name))
+		}
+	}
+
+	if len(immutable) > 0 {
+		changes = append(changes, fmt.Sprintf("recreate StatefulSet %s: %s", oldSts.Name, strings.Join(immutable, ", ")))
+	}
+
+	return changes, nil
+}
+
+// patchedStatefulSet returns sts with patch applied to it, the way the API
+// server would.
+func patchedStatefulSet(sts *appsv1.StatefulSet, patch []byte) (*appsv1.StatefulSet, error) {
+	current, err := json.Marshal(sts)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := strategicpatch.StrategicMergePatch(current, patch, appsv1.StatefulSet{})
+	if err != nil {
+		return nil, err
+	}
+
+	var patched appsv1.StatefulSet
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// planPeerConfigMap returns the change handleConfigMap would make to the peer
+// ConfigMap cm, which is nil if it doesn't exist, given the running Pods.
+func planPeerConfigMap(cm *apiv1.ConfigMap, runningPods []apiv1.Pod) string {
+	leaderIP := ""
+	if len(runningPods) > 0 {
+		leaderIP = runningPods[0].Status.PodIP
+	}
+
+	if cm == nil {
+		return fmt.Sprintf("create ConfigMap %s: peer IP %q", configMapName, leaderIP)
+	}
+
+	curLeader := cm.Data[peerFile]
+	for _, p := range runningPods {
+		if p.Status.PodIP == curLeader {
+			return ""
+		}
+	}
+
+	if curLeader == leaderIP {
+		return ""
+	}
+
+	return fmt.Sprintf("update ConfigMap %s: peer IP from %q to %q", cm.Name, curLeader, leaderIP)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"strings"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPlanStatefulSet(t *testing.T) {
+	oldSts := newTestHashedStatefulSet(t, "foo/bar:1")
+	oldSts.Status.Replicas = 3
+	if err := setLastApplied(oldSts); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := planStatefulSet(oldSts, newTestHashedStatefulSet(t, "foo/bar:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("planStatefulSet() of an unchanged StatefulSet = %v, want none", changes)
+	}
+
+	newSts := newTestHashedStatefulSet(t, "foo/bar:2")
+	newSts.Spec.VolumeClaimTemplates = append(newSts.Spec.VolumeClaimTemplates, newTestClaimTemplate("data", "1Gi"))
+
+	changes, err = planStatefulSet(oldSts, newSts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"update StatefulSet foo", "delete the 3 Pods of StatefulSet foo", "recreate StatefulSet foo"}
+	if len(changes) != len(want) {
+		t.Fatalf("planStatefulSet() = %v, want %d changes", changes, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(changes[i], w) {
+			t.Errorf("planStatefulSet()[%d] = %q, want it to start with %q", i, changes[i], w)
+		}
+	}
+
+	// Planning doesn't modify the desired StatefulSet.
+	if len(newSts.Spec.VolumeClaimTemplates) != 1 {
+		t.Errorf("planStatefulSet() modified the claim templates of the desired StatefulSet")
+	}
+}
+
+func TestPlanPeerConfigMap(t *testing.T) {
+	pod := func(ip string) apiv1.Pod {
+		p := apiv1.Pod{}
+		p.Status.PodIP = ip
+		return p
+	}
+	cm := func(ip string) *apiv1.ConfigMap {
+		return &apiv1.ConfigMap{Data: map[string]string{peerFile: ip}}
+	}
+
+	tests := []struct {
+		name    string
+		cm      *apiv1.ConfigMap
+		pods    []apiv1.Pod
+		changed bool
+	}{
+		{"missing", nil, nil, true},
+		{"leader running", cm("10.0.0.2"), []apiv1.Pod{pod("10.0.0.1"), pod("10.0.0.2")}, false},
+		{"leader gone", cm("10.0.0.3"), []apiv1.Pod{pod("10.0.0.1")}, true},
+		{"no Pods", cm(""), nil, false},
+		{"no Pods left", cm("10.0.0.1"), nil, true},
+	}
+
+	for _, tt := range tests {
+		if got := planPeerConfigMap(tt.cm, tt.pods); (got != "") != tt.changed {
+			t.Errorf("%s: planPeerConfigMap() = %q, want a change: %t", tt.name, got, tt.changed)
+		}
+	}
+}
+
+func TestPlanResources(t *testing.T) {
+	newInformer := func(obj runtime.Object) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(&cache.ListWatch{}, obj, 0, cache.Indexers{})
+	}
+
+	hc := &HabitatController{
+		logger:         log.NewNopLogger(),
+		objects:        newStaticObjectGetter(nil, nil),
+		habInformer:    cache.NewSharedIndexInformer(&cache.ListWatch{}, &habv1beta1.Habitat{}, 0, cache.Indexers{bindTargetIndex: bindTargetIndexFunc}),
+		nwpInformer:    newInformer(&networkingv1.NetworkPolicy{}),
+		pdbInformer:    newInformer(&policyv1beta1.PodDisruptionBudget{}),
+		secretInformer: newInformer(&apiv1.Secret{}),
+	}
+
+	h := newTestHabitat("default", "foo", "redis", nil)
+	h.UID = types.UID("foo-uid")
+	h.Spec.V1beta2.NetworkPolicy = &habv1beta1.NetworkPolicy{}
+	h.Spec.V1beta2.Service.Config = &habv1beta1.ServiceConfig{TOML: "port = 6379"}
+
+	plan := func() []string {
+		var changes []string
+		for _, f := range []func(*habv1beta1.Habitat) (string, error){hc.planNetworkPolicy, hc.planPodDisruptionBudget, hc.planUserConfigSecret} {
+			c, err := f(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != "" {
+				changes = append(changes, c)
+			}
+		}
+		return changes
+	}
+
+	want := []string{"create NetworkPolicy foo", "create PodDisruptionBudget foo", "create Secret foo-user-config"}
+	if got := plan(); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("planned changes = %v, want %v", got, want)
+	}
+
+	// Once the resources are up to date, nothing is planned.
+	np := newNetworkPolicy(h, nil)
+	if err := setLastApplied(np); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := hc.renderUserConfigSecret(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdb := newPodDisruptionBudget(h)
+	pdb.Namespace = h.Namespace
+	for informer, obj := range map[cache.SharedIndexInformer]interface{}{hc.nwpInformer: np, hc.pdbInformer: pdb, hc.secretInformer: secret} {
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := plan(); len(got) != 0 {
+		t.Errorf("planned changes of up to date resources = %v, want none", got)
+	}
+
+	// Resources which are no longer needed are deleted.
+	h.Spec.V1beta2.NetworkPolicy = nil
+	h.Spec.V1beta2.PodDisruptionBudget = &habv1beta1.PodDisruptionBudget{Disabled: true}
+	h.Spec.V1beta2.Service.Config = nil
+	want = []string{"delete NetworkPolicy foo", "delete PodDisruptionBudget foo", "delete Secret foo-user-config"}
+	if got := plan(); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("planned changes = %v, want %v", got, want)
+	}
+}
+
+// Nothing is planned for the Habitats reconcile would hold back, and their
+// status is left as it is.
+func TestPlanHeldBack(t *testing.T) {
+	bind := func(service string) habv1beta1.Bind {
+		return habv1beta1.Bind{Name: service, Service: service, Group: "default"}
+	}
+
+	api := newTestHabitat("default", "api", "api", nil, bind("auth"))
+	auth := newTestHabitat("default", "auth", "auth", nil, bind("api"))
+	web := newTestHabitat("default", "web", "nginx", nil, bind("db"))
+	web.Spec.V1beta2.Service.WaitForBinds = true
+	paused := newTestHabitat("default", "paused", "redis", nil)
+	paused.Spec.V1beta2.Paused = true
+
+	recorder := record.NewFakeRecorder(10)
+	hc := &HabitatController{
+		logger:      log.NewNopLogger(),
+		recorder:    recorder,
+		habInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &habv1beta1.Habitat{}, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, serviceGroupIndex: serviceGroupIndexFunc, bindTargetIndex: bindTargetIndexFunc}),
+		stsInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &appsv1.StatefulSet{}, 0, cache.Indexers{}),
+	}
+	for _, h := range []*habv1beta1.Habitat{api, auth, web, paused} {
+		if err := hc.habInformer.GetIndexer().Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, h := range []*habv1beta1.Habitat{api, web, paused} {
+		if err := hc.plan(h); err != nil {
+			t.Errorf("plan(%s) error = %v", h.Name, err)
+		}
+		if len(h.Status.Conditions) != 0 {
+			t.Errorf("plan(%s) set conditions %v, want none", h.Name, h.Status.Conditions)
+		}
+	}
+	if n := len(recorder.Events); n != 0 {
+		t.Errorf("plan() recorded %d events, want none", n)
+	}
+}
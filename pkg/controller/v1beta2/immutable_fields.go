@@ -0,0 +1,138 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// immutableChanges returns the paths of the fields of the spec of oldSts
+// which can't be updated and differ in newSts. Fields which newSts leaves to
+// the API server's defaults, and the sizes of the claim templates, which are
+// handled by reconcileVolumeExpansion, are ignored.
+func immutableChanges(oldSts, newSts *appsv1.StatefulSet) []string {
+	var changes []string
+
+	o, n := oldSts.Spec, newSts.Spec
+
+	if !reflect.DeepEqual(o.Selector, n.Selector) {
+		changes = append(changes, "spec.selector")
+	}
+	if o.ServiceName != n.ServiceName {
+		changes = append(changes, "spec.serviceName")
+	}
+	if n.PodManagementPolicy != "" && o.PodManagementPolicy != n.PodManagementPolicy {
+		changes = append(changes, "spec.podManagementPolicy")
+	}
+	if n.RevisionHistoryLimit != nil && !reflect.DeepEqual(o.RevisionHistoryLimit, n.RevisionHistoryLimit) {
+		changes = append(changes, "spec.revisionHistoryLimit")
+	}
+	if !claimTemplatesEqual(o.VolumeClaimTemplates, n.VolumeClaimTemplates) {
+		changes = append(changes, "spec.volumeClaimTemplates")
+	}
+
+	return changes
+}
+
+// claimTemplatesEqual compares the fields of the claim templates set by the
+// operator, except for their size.
+func claimTemplatesEqual(oldTemplates, newTemplates []apiv1.PersistentVolumeClaim) bool {
+	if len(oldTemplates) != len(newTemplates) {
+		return false
+	}
+
+	for i := range newTemplates {
+		o, n := oldTemplates[i], newTemplates[i]
+
+		if o.Name != n.Name ||
+			!stringMapsEqual(o.Labels, n.Labels) ||
+			!stringMapsEqual(o.Annotations, n.Annotations) ||
+			!reflect.DeepEqual(o.Spec.AccessModes, n.Spec.AccessModes) ||
+			!reflect.DeepEqual(o.Spec.StorageClassName, n.Spec.StorageClassName) ||
+			!reflect.DeepEqual(o.Spec.Selector, n.Spec.Selector) {
+			return false
+		}
+
+		if n.Spec.VolumeMode != nil && !reflect.DeepEqual(o.Spec.VolumeMode, n.Spec.VolumeMode) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stringMapsEqual treats nil and empty maps as equal.
+func stringMapsEqual(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// preserveImmutableFields copies the fields of the spec of oldSts which can't
+// be updated to newSts, so that the rest of its spec can be updated.
+func preserveImmutableFields(oldSts, newSts *appsv1.StatefulSet) {
+	newSts.Spec.Selector = oldSts.Spec.Selector
+	newSts.Spec.ServiceName = oldSts.Spec.ServiceName
+	newSts.Spec.PodManagementPolicy = oldSts.Spec.PodManagementPolicy
+	newSts.Spec.RevisionHistoryLimit = oldSts.Spec.RevisionHistoryLimit
+	newSts.Spec.VolumeClaimTemplates = oldSts.Spec.VolumeClaimTemplates
+}
+
+// reconcileImmutableChanges decides how the changes to the immutable fields
+// of oldSts in newSts are applied, and records it in the status of the Habitat
+// h. It returns whether oldSts has to be recreated, where recreate tells
+// whether other changes, such as resized volumes, already require it.
+func (hc *HabitatController) reconcileImmutableChanges(h *habv1beta1.Habitat, oldSts, newSts *appsv1.StatefulSet, recreate bool) bool {
+	changes := immutableChanges(oldSts, newSts)
+
+	// The replacement of a StatefulSet only adopts the Pods matching its
+	// selector, so a changed selector would leave the current Pods running
+	// unmanaged, next to new ones.
+	for _, c := range changes {
+		if c != "spec.selector" {
+			continue
+		}
+
+		msg := fmt.Sprintf("The StatefulSet %s can't be updated, as its selector changed. Delete it, along with its Pods, to recreate it", oldSts.Name)
+		if c := getCondition(h.Status, habv1beta1.HabitatConditionImmutableFieldsChanged); c == nil || c.Message != msg {
+			hc.recorder.Event(h, apiv1.EventTypeWarning, stsImmutableFieldsChanged, msg)
+		}
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionImmutableFieldsChanged, true, "SelectorChanged", msg))
+
+		// Volumes which were already expanded still need the StatefulSet to be
+		// recreated, so that the claims created later get their new size.
+		return recreate
+	}
+
+	removeCondition(&h.Status, habv1beta1.HabitatConditionImmutableFieldsChanged)
+
+	if len(changes) == 0 {
+		return recreate
+	}
+
+	msg := fmt.Sprintf("Recreating the StatefulSet %s to change %s", oldSts.Name, strings.Join(changes, ", "))
+	hc.recorder.Event(h, apiv1.EventTypeNormal, stsRecreated, msg)
+
+	return true
+}
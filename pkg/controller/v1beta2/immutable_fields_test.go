@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestImmutableStatefulSet() *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{}
+	sts.Name = "foo"
+	sts.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"habitat-name": "foo"},
+	}
+	sts.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+	sts.Spec.VolumeClaimTemplates = []apiv1.PersistentVolumeClaim{
+		newTestClaimTemplate("persistent", "1Gi"),
+	}
+
+	return sts
+}
+
+func TestImmutableChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(sts *appsv1.StatefulSet)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(sts *appsv1.StatefulSet) {},
+		},
+		{
+			name: "resized volume",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.VolumeClaimTemplates[0] = newTestClaimTemplate("persistent", "2Gi")
+			},
+		},
+		{
+			name: "defaulted fields",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.PodManagementPolicy = ""
+				sts.Spec.RevisionHistoryLimit = nil
+			},
+		},
+		{
+			name: "added volume",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, newTestClaimTemplate("logs", "1Gi"))
+			},
+			want: []string{"spec.volumeClaimTemplates"},
+		},
+		{
+			name: "access modes",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.VolumeClaimTemplates[0].Spec.AccessModes = []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteMany}
+			},
+			want: []string{"spec.volumeClaimTemplates"},
+		},
+		{
+			name: "selector and service name",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.Selector = &metav1.LabelSelector{
+					MatchLabels: map[string]string{"habitat-name": "bar"},
+				}
+				sts.Spec.ServiceName = "foo"
+			},
+			want: []string{"spec.selector", "spec.serviceName"},
+		},
+	}
+
+	for _, tt := range tests {
+		oldSts := newTestImmutableStatefulSet()
+		oldSts.Spec.RevisionHistoryLimit = int32ToPtr(10)
+
+		newSts := newTestImmutableStatefulSet()
+		tt.change(newSts)
+
+		got := immutableChanges(oldSts, newSts)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: immutableChanges() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPreserveImmutableFields(t *testing.T) {
+	oldSts := newTestImmutableStatefulSet()
+	newSts := newTestImmutableStatefulSet()
+	newSts.Spec.ServiceName = "foo"
+	newSts.Spec.VolumeClaimTemplates = nil
+
+	preserveImmutableFields(oldSts, newSts)
+
+	if changes := immutableChanges(oldSts, newSts); len(changes) != 0 {
+		t.Errorf("immutableChanges() after preserveImmutableFields() = %v, want none", changes)
+	}
+}
+
+func TestReconcileImmutableChangesSelector(t *testing.T) {
+	h := newTestHabitat("default", "foo", "redis", nil)
+	hc := &HabitatController{recorder: record.NewFakeRecorder(10)}
+
+	oldSts := newTestImmutableStatefulSet()
+	newSts := newTestImmutableStatefulSet()
+	newSts.Spec.Selector.MatchLabels["habitat-name"] = "bar"
+
+	if hc.reconcileImmutableChanges(h, oldSts, newSts, false) {
+		t.Error("reconcileImmutableChanges() of a changed selector = true, want false")
+	}
+	if !hc.reconcileImmutableChanges(h, oldSts, newSts, true) {
+		t.Error("reconcileImmutableChanges() of a changed selector and resized volumes = false, want true")
+	}
+	if c := getCondition(h.Status, habv1beta1.HabitatConditionImmutableFieldsChanged); c == nil {
+		t.Error("ImmutableFieldsChanged condition not set")
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	habinformers "github.com/habitat-sh/habitat-operator/pkg/client/informers/externalversions"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceInformerFactories are the informer factories of a single namespace
+// watched by the controller.
+type NamespaceInformerFactories struct {
+	KubeInformerFactory    kubeinformers.SharedInformerFactory
+	HabitatInformerFactory habinformers.SharedInformerFactory
+}
+
+// watchedNamespaces returns the namespaces the controller watches, which is
+// the single namespace of its config, possibly metav1.NamespaceAll, unless it
+// watches several namespaces.
+func (hc *HabitatController) watchedNamespaces() []string {
+	if len(hc.config.Namespaces) == 0 {
+		return []string{hc.config.Namespace}
+	}
+
+	namespaces := make([]string, 0, len(hc.config.Namespaces))
+	for ns := range hc.config.Namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// kubeInformer returns the informer created by informerFor from the Kubernetes
+// informer factories of the watched namespaces.
+func (hc *HabitatController) kubeInformer(informerFor func(kubeinformers.SharedInformerFactory) cache.SharedIndexInformer) cache.SharedIndexInformer {
+	if len(hc.config.Namespaces) == 0 {
+		return informerFor(hc.config.KubeInformerFactory)
+	}
+
+	informers := map[string]cache.SharedIndexInformer{}
+	for ns, f := range hc.config.Namespaces {
+		informers[ns] = informerFor(f.KubeInformerFactory)
+	}
+
+	return newMultiNamespaceInformer(informers)
+}
+
+// habitatInformer returns the Habitat informer of the watched namespaces.
+func (hc *HabitatController) habitatInformer() cache.SharedIndexInformer {
+	if len(hc.config.Namespaces) == 0 {
+		return hc.config.HabitatInformerFactory.Habitat().V1beta1().Habitats().Informer()
+	}
+
+	informers := map[string]cache.SharedIndexInformer{}
+	for ns, f := range hc.config.Namespaces {
+		informers[ns] = f.HabitatInformerFactory.Habitat().V1beta1().Habitats().Informer()
+	}
+
+	return newMultiNamespaceInformer(informers)
+}
+
+// multiNamespaceInformer combines the informers of a resource in several
+// namespaces into one.
+type multiNamespaceInformer struct {
+	informers map[string]cache.SharedIndexInformer
+}
+
+func newMultiNamespaceInformer(informers map[string]cache.SharedIndexInformer) *multiNamespaceInformer {
+	return &multiNamespaceInformer{informers: informers}
+}
+
+func (m *multiNamespaceInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, i := range m.informers {
+		i.AddEventHandler(handler)
+	}
+}
+
+func (m *multiNamespaceInformer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, i := range m.informers {
+		i.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+func (m *multiNamespaceInformer) GetStore() cache.Store {
+	return m.GetIndexer()
+}
+
+// GetController returns nil, as each namespace has its own controller.
+func (m *multiNamespaceInformer) GetController() cache.Controller {
+	return nil
+}
+
+// Run runs the informers of all the namespaces until stopCh is closed.
+func (m *multiNamespaceInformer) Run(stopCh <-chan struct{}) {
+	var wg sync.WaitGroup
+	wg.Add(len(m.informers))
+
+	for _, i := range m.informers {
+		go func(i cache.SharedIndexInformer) {
+			i.Run(stopCh)
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func (m *multiNamespaceInformer) HasSynced() bool {
+	for _, i := range m.informers {
+		if !i.HasSynced() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LastSyncResourceVersion returns an empty string, as resource versions of
+// different informers can't be compared.
+func (m *multiNamespaceInformer) LastSyncResourceVersion() string {
+	return ""
+}
+
+func (m *multiNamespaceInformer) AddIndexers(indexers cache.Indexers) error {
+	for _, i := range m.informers {
+		if err := i.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *multiNamespaceInformer) GetIndexer() cache.Indexer {
+	indexers := map[string]cache.Indexer{}
+	for ns, i := range m.informers {
+		indexers[ns] = i.GetIndexer()
+	}
+
+	return multiNamespaceIndexer{indexers: indexers}
+}
+
+// multiNamespaceIndexer combines the indexers of a resource in several
+// namespaces into one. Lookups by key or object are routed to the indexer of
+// their namespace, and the results of the other lookups concatenated.
+type multiNamespaceIndexer struct {
+	indexers map[string]cache.Indexer
+}
+
+// indexerFor returns the indexer of the namespace of obj.
+func (m multiNamespaceIndexer) indexerFor(obj interface{}) (cache.Indexer, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	i, ok := m.indexers[o.GetNamespace()]
+	if !ok {
+		return nil, fmt.Errorf("namespace %q isn't watched", o.GetNamespace())
+	}
+
+	return i, nil
+}
+
+func (m multiNamespaceIndexer) Add(obj interface{}) error {
+	i, err := m.indexerFor(obj)
+	if err != nil {
+		return err
+	}
+
+	return i.Add(obj)
+}
+
+func (m multiNamespaceIndexer) Update(obj interface{}) error {
+	i, err := m.indexerFor(obj)
+	if err != nil {
+		return err
+	}
+
+	return i.Update(obj)
+}
+
+func (m multiNamespaceIndexer) Delete(obj interface{}) error {
+	i, err := m.indexerFor(obj)
+	if err != nil {
+		return err
+	}
+
+	return i.Delete(obj)
+}
+
+func (m multiNamespaceIndexer) List() []interface{} {
+	var list []interface{}
+	for _, i := range m.indexers {
+		list = append(list, i.List()...)
+	}
+
+	return list
+}
+
+func (m multiNamespaceIndexer) ListKeys() []string {
+	var keys []string
+	for _, i := range m.indexers {
+		keys = append(keys, i.ListKeys()...)
+	}
+
+	return keys
+}
+
+func (m multiNamespaceIndexer) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return m.GetByKey(key)
+}
+
+func (m multiNamespaceIndexer) GetByKey(key string) (interface{}, bool, error) {
+	ns, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	i, ok := m.indexers[ns]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return i.GetByKey(key)
+}
+
+// Replace isn't supported, as the indexers are replaced by their own
+// informers.
+func (m multiNamespaceIndexer) Replace(list []interface{}, resourceVersion string) error {
+	return fmt.Errorf("replacing the objects of several namespaces isn't supported")
+}
+
+func (m multiNamespaceIndexer) Resync() error {
+	for _, i := range m.indexers {
+		if err := i.Resync(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m multiNamespaceIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var list []interface{}
+	for _, i := range m.indexers {
+		l, err := i.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l...)
+	}
+
+	return list, nil
+}
+
+func (m multiNamespaceIndexer) IndexKeys(indexName, indexKey string) ([]string, error) {
+	var keys []string
+	for _, i := range m.indexers {
+		k, err := i.IndexKeys(indexName, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k...)
+	}
+
+	return keys, nil
+}
+
+func (m multiNamespaceIndexer) ListIndexFuncValues(indexName string) []string {
+	seen := map[string]bool{}
+	var values []string
+	for _, i := range m.indexers {
+		for _, v := range i.ListIndexFuncValues(indexName) {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values
+}
+
+func (m multiNamespaceIndexer) ByIndex(indexName, indexKey string) ([]interface{}, error) {
+	var list []interface{}
+	for _, i := range m.indexers {
+		l, err := i.ByIndex(indexName, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l...)
+	}
+
+	return list, nil
+}
+
+// GetIndexers returns the indexers of any namespace, as they're all added
+// together.
+func (m multiNamespaceIndexer) GetIndexers() cache.Indexers {
+	for _, i := range m.indexers {
+		return i.GetIndexers()
+	}
+
+	return cache.Indexers{}
+}
+
+func (m multiNamespaceIndexer) AddIndexers(indexers cache.Indexers) error {
+	for _, i := range m.indexers {
+		if err := i.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
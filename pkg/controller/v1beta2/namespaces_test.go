@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMultiNamespaceIndexer(t *testing.T) {
+	db := newTestHabitat("myproject", "db", "postgresql", nil)
+	redis := newTestHabitat("shared", "cache", "redis", strToPtr("redisdb"))
+
+	indexer := multiNamespaceIndexer{indexers: map[string]cache.Indexer{
+		"myproject": newTestHabitatIndexer(t, db),
+		"shared":    newTestHabitatIndexer(t, redis),
+	}}
+
+	if got := len(indexer.List()); got != 2 {
+		t.Errorf("List() returned %d objects, want 2", got)
+	}
+
+	obj, exists, err := indexer.GetByKey("shared/cache")
+	if err != nil || !exists || obj != redis {
+		t.Errorf("GetByKey(shared/cache) = %v, %v, %v, want %v", obj, exists, err, redis)
+	}
+
+	if _, exists, err := indexer.GetByKey("other/cache"); err != nil || exists {
+		t.Errorf("GetByKey(other/cache) = %v, %v, want no object", exists, err)
+	}
+
+	objs, err := indexer.ByIndex(serviceGroupIndex, "shared/redis.redisdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0] != redis {
+		t.Errorf("ByIndex() = %v, want [%v]", objs, redis)
+	}
+
+	if err := indexer.Add(newTestHabitat("other", "db", "postgresql", nil)); err == nil {
+		t.Error("Add() of an object in an unwatched namespace succeeded")
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"sort"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newNetworkPolicy returns the NetworkPolicy of the Habitat h, allowing
+// traffic from the Habitats in consumers.
+func newNetworkPolicy(h *habv1beta1.Habitat, consumers []*habv1beta1.Habitat) *networkingv1.NetworkPolicy {
+	hs := h.Spec.V1beta2
+
+	tcp := apiv1.ProtocolTCP
+	udp := apiv1.ProtocolUDP
+	gossip := intstr.FromString(gossipPortName)
+	gossipUDP := intstr.FromString(gossipUDPPortName)
+
+	// All the supervisors in a namespace are part of the same ring, so they
+	// need to gossip with each other.
+	rules := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							habv1beta1.HabitatLabel: "true",
+						},
+					},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: &gossip},
+				{Protocol: &udp, Port: &gossipUDP},
+			},
+		},
+	}
+
+	if len(consumers) > 0 {
+		rule := networkingv1.NetworkPolicyIngressRule{}
+		for _, c := range consumers {
+			rule.From = append(rule.From, networkingv1.NetworkPolicyPeer{
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						habv1beta1.HabitatNameLabel: c.Name,
+					},
+				},
+			})
+		}
+
+		// When the service doesn't declare its ports, consumers are allowed to
+		// reach any port.
+		for _, p := range hs.Service.Ports {
+			protocol := apiv1.ProtocolTCP
+			if p.Protocol != "" {
+				protocol = p.Protocol
+			}
+
+			port := intstr.FromInt(int(p.ContainerPort))
+			if p.Name != "" {
+				port = intstr.FromString(p.Name)
+			}
+
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{
+				Protocol: &protocol,
+				Port:     &port,
+			})
+		}
+
+		rules = append(rules, rule)
+	}
+
+	for _, r := range hs.NetworkPolicy.Ingress {
+		rules = append(rules, *r.DeepCopy())
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.Name,
+			Namespace: h.Namespace,
+			Labels: map[string]string{
+				habv1beta1.HabitatLabel:     "true",
+				habv1beta1.HabitatNameLabel: h.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				metav1.OwnerReference{
+					APIVersion: habv1beta1.SchemeGroupVersion.String(),
+					Kind:       habv1beta1.HabitatKind,
+					Name:       h.Name,
+					UID:        h.UID,
+				},
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					habv1beta1.HabitatNameLabel: h.Name,
+				},
+			},
+			Ingress:     rules,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+// sameNamespaceConsumers returns the Habitats in the namespace of h binding to
+// the service it provides, sorted by name.
+func sameNamespaceConsumers(indexer cache.Indexer, h *habv1beta1.Habitat) ([]*habv1beta1.Habitat, error) {
+	objs, err := indexer.ByIndex(bindTargetIndex, providedServiceGroupKey(h))
+	if err != nil {
+		return nil, err
+	}
+
+	var consumers []*habv1beta1.Habitat
+	for _, obj := range objs {
+		c, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			return nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+		}
+
+		if c.Namespace == h.Namespace && checkCustomVersionMatch(c.CustomVersion) == nil {
+			consumers = append(consumers, c)
+		}
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+
+	return consumers, nil
+}
+
+// handleNetworkPolicy creates, updates or deletes the NetworkPolicy of the
+// Habitat h, depending on its spec.
+func (hc *HabitatController) handleNetworkPolicy(h *habv1beta1.Habitat) error {
+	obj, exists, err := hc.nwpInformer.GetStore().GetByKey(habitatKey(h))
+	if err != nil {
+		return err
+	}
+
+	var np *networkingv1.NetworkPolicy
+	if exists {
+		var ok bool
+		if np, ok = obj.(*networkingv1.NetworkPolicy); !ok {
+			return fmt.Errorf("unknown object type in NetworkPolicy cache: %v", obj)
+		}
+
+		// NetworkPolicies created by users with the same name are left alone.
+		if !ownedByHabitat(np, h) {
+			hc.recorder.Eventf(h, apiv1.EventTypeWarning, resourceConflict, messageResourceConflict, "NetworkPolicy", np.Name)
+			return nil
+		}
+	}
+
+	networkPolicies := hc.config.KubernetesClientset.NetworkingV1().NetworkPolicies(h.Namespace)
+
+	if h.Spec.V1beta2.NetworkPolicy == nil {
+		if !exists {
+			return nil
+		}
+
+		err := networkPolicies.Delete(h.Name, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &np.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", "deleted NetworkPolicy", "name", h.Name)
+
+		return nil
+	}
+
+	consumers, err := sameNamespaceConsumers(hc.habInformer.GetIndexer(), h)
+	if err != nil {
+		return err
+	}
+
+	newNP := newNetworkPolicy(h, consumers)
+
+	if err := setLastApplied(newNP); err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := networkPolicies.Create(newNP); err != nil {
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", "created NetworkPolicy", "name", newNP.Name)
+		hc.recorder.Event(h, apiv1.EventTypeNormal, nwpCreated, messageNwpCreated)
+
+		return nil
+	}
+
+	patch, err := applyPatch(newNP, np, networkingv1.NetworkPolicy{})
+	if err != nil {
+		return err
+	}
+
+	if patch == nil {
+		return nil
+	}
+
+	if _, err := networkPolicies.Patch(np.Name, types.StrategicMergePatchType, patch); err != nil {
+		return err
+	}
+
+	level.Debug(hc.logger).Log("msg", "updated NetworkPolicy", "name", np.Name)
+
+	return nil
+}
+
+func (hc *HabitatController) cacheNetworkPolicies() {
+	hc.nwpInformer = hc.kubeInformer(func(f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Networking().V1().NetworkPolicies().Informer()
+	})
+
+	hc.nwpInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    hc.handleNwp,
+		UpdateFunc: hc.handleNwpUpdate,
+		DeleteFunc: hc.handleNwp,
+	})
+
+	hc.nwpInformerSynced = hc.nwpInformer.HasSynced
+}
+
+func (hc *HabitatController) handleNwp(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	np, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert NetworkPolicy", "obj", obj)
+		return
+	}
+
+	if !isHabitatObject(&np.ObjectMeta) {
+		return
+	}
+
+	h, err := hc.getHabitatFromLabeledResource(np)
+	if err != nil {
+		// Could not find Habitat, it must have already been removed.
+		level.Debug(hc.logger).Log("msg", "Could not find Habitat for NetworkPolicy", "name", np.Name)
+		return
+	}
+
+	hc.enqueue(h)
+}
+
+func (hc *HabitatController) handleNwpUpdate(oldObj, newObj interface{}) {
+	oldNP, ok := oldObj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert NetworkPolicy", "obj", oldObj)
+		return
+	}
+
+	newNP, ok := newObj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert NetworkPolicy", "obj", newObj)
+		return
+	}
+
+	if oldNP.ResourceVersion == newNP.ResourceVersion {
+		return
+	}
+
+	hc.handleNwp(newNP)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestNewNetworkPolicy(t *testing.T) {
+	bind := habv1beta1.Bind{Name: "db", Service: "postgresql", Group: "default"}
+	db := newTestHabitat("myproject", "db", "postgresql", nil)
+	db.Spec.V1beta2.Service.Ports = []apiv1.ContainerPort{{Name: "postgresql", ContainerPort: 5432}}
+	db.Spec.V1beta2.NetworkPolicy = &habv1beta1.NetworkPolicy{
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+	}
+	web := newTestHabitat("myproject", "web", "nginx", nil, bind)
+	other := newTestHabitat("other", "web", "nginx", nil, bind)
+	indexer := newTestHabitatIndexer(t, db, web, other)
+
+	consumers, err := sameNamespaceConsumers(indexer, db)
+	if err != nil {
+		t.Fatalf("sameNamespaceConsumers() error = %v", err)
+	}
+	if len(consumers) != 1 || consumers[0] != web {
+		t.Fatalf("sameNamespaceConsumers() = %v, want [web]", consumers)
+	}
+
+	np := newNetworkPolicy(db, consumers)
+
+	if got := np.Spec.PodSelector.MatchLabels[habv1beta1.HabitatNameLabel]; got != "db" {
+		t.Errorf("pod selector = %q, want db", got)
+	}
+
+	rules := np.Spec.Ingress
+	if len(rules) != 3 {
+		t.Fatalf("got %d ingress rules, want 3", len(rules))
+	}
+
+	gossip := rules[0]
+	if gossip.From[0].PodSelector.MatchLabels[habv1beta1.HabitatLabel] != "true" || len(gossip.Ports) != 2 {
+		t.Errorf("gossip rule = %+v", gossip)
+	}
+
+	tcp := apiv1.ProtocolTCP
+	port := intstr.FromString("postgresql")
+	wantPorts := []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}}
+	binds := rules[1]
+	if len(binds.From) != 1 || binds.From[0].PodSelector.MatchLabels[habv1beta1.HabitatNameLabel] != "web" {
+		t.Errorf("bind rule peers = %+v", binds.From)
+	}
+	if !reflect.DeepEqual(binds.Ports, wantPorts) {
+		t.Errorf("bind rule ports = %+v, want %+v", binds.Ports, wantPorts)
+	}
+}
+
+// The controller has no clientset, so changing the NetworkPolicy panics.
+func TestHandleNetworkPolicyNotOwned(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	hc := &HabitatController{
+		recorder:    recorder,
+		nwpInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &networkingv1.NetworkPolicy{}, 0, cache.Indexers{}),
+	}
+
+	h := newTestHabitat("myproject", "db", "postgresql", nil)
+	h.UID = types.UID("db-uid")
+
+	// A NetworkPolicy created by a user with the name of the Habitat.
+	np := &networkingv1.NetworkPolicy{}
+	np.Name = h.Name
+	np.Namespace = h.Namespace
+	if err := hc.nwpInformer.GetIndexer().Add(np); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []*habv1beta1.NetworkPolicy{nil, {}} {
+		h.Spec.V1beta2.NetworkPolicy = spec
+		if err := hc.handleNetworkPolicy(h); err != nil {
+			t.Fatalf("handleNetworkPolicy() error = %v", err)
+		}
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("recorded %d events, want 2 conflicts", len(recorder.Events))
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// objectGetter reads the Secrets and ConfigMaps referenced by Habitats.
+type objectGetter interface {
+	getSecret(namespace, name string) (*apiv1.Secret, error)
+	getConfigMap(namespace, name string) (*apiv1.ConfigMap, error)
+}
+
+// clientObjectGetter reads objects from the API server.
+type clientObjectGetter struct {
+	clientset kubernetes.Interface
+}
+
+func (c clientObjectGetter) getSecret(namespace, name string) (*apiv1.Secret, error) {
+	return c.clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c clientObjectGetter) getConfigMap(namespace, name string) (*apiv1.ConfigMap, error) {
+	return c.clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+}
+
+// staticObjectGetter reads objects from a fixed set, such as the objects read
+// from manifests, indexed by their namespace and name.
+type staticObjectGetter struct {
+	secrets    map[string]*apiv1.Secret
+	configMaps map[string]*apiv1.ConfigMap
+}
+
+func newStaticObjectGetter(secrets []*apiv1.Secret, configMaps []*apiv1.ConfigMap) *staticObjectGetter {
+	s := &staticObjectGetter{
+		secrets:    map[string]*apiv1.Secret{},
+		configMaps: map[string]*apiv1.ConfigMap{},
+	}
+
+	for _, secret := range secrets {
+		s.secrets[fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)] = secret
+	}
+	for _, cm := range configMaps {
+		s.configMaps[fmt.Sprintf("%s/%s", cm.Namespace, cm.Name)] = cm
+	}
+
+	return s
+}
+
+func (s *staticObjectGetter) getSecret(namespace, name string) (*apiv1.Secret, error) {
+	secret, ok := s.secrets[fmt.Sprintf("%s/%s", namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(apiv1.Resource("secrets"), name)
+	}
+
+	return secret, nil
+}
+
+func (s *staticObjectGetter) getConfigMap(namespace, name string) (*apiv1.ConfigMap, error) {
+	cm, ok := s.configMaps[fmt.Sprintf("%s/%s", namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(apiv1.Resource("configmaps"), name)
+	}
+
+	return cm, nil
+}
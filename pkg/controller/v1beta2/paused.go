@@ -0,0 +1,43 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// reconcilePaused records in the status of the Habitat h whether its
+// reconciliation is paused, and returns true if it is.
+func (hc *HabitatController) reconcilePaused(h *habv1beta1.Habitat) bool {
+	c := getCondition(h.Status, habv1beta1.HabitatConditionPaused)
+
+	if !h.Spec.V1beta2.Paused {
+		if c != nil {
+			hc.recorder.Event(h, apiv1.EventTypeNormal, resumed, messageResumed)
+			removeCondition(&h.Status, habv1beta1.HabitatConditionPaused)
+		}
+
+		return false
+	}
+
+	if c == nil {
+		hc.recorder.Event(h, apiv1.EventTypeNormal, paused, messagePaused)
+	}
+	setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionPaused, true, "Paused", "The resources of the Habitat aren't updated until it is resumed"))
+
+	return true
+}
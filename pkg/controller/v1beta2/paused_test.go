@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	hc := &HabitatController{recorder: recorder}
+
+	h := newTestHabitat("default", "foo", "foo", nil)
+	h.Spec.V1beta2.Paused = true
+
+	for i := 0; i < 2; i++ {
+		if !hc.reconcilePaused(h) {
+			t.Fatal("reconcilePaused() = false for a paused Habitat, want true")
+		}
+	}
+	if c := getCondition(h.Status, habv1beta1.HabitatConditionPaused); c == nil {
+		t.Fatal("paused Habitat has no Paused condition")
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("recorded %d events while paused, want 1", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	h.Spec.V1beta2.Paused = false
+	if hc.reconcilePaused(h) {
+		t.Fatal("reconcilePaused() = true for a resumed Habitat, want false")
+	}
+	if c := getCondition(h.Status, habv1beta1.HabitatConditionPaused); c != nil {
+		t.Errorf("resumed Habitat has condition %+v, want none", c)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("recorded %d events when resumed, want 1", len(recorder.Events))
+	}
+}
+
+// The controller has neither clientset nor informers, so reconciling anything
+// but the Paused condition panics.
+func TestReconcileWhilePaused(t *testing.T) {
+	hc := &HabitatController{
+		logger:   log.NewNopLogger(),
+		recorder: record.NewFakeRecorder(10),
+	}
+
+	h := newTestHabitat("default", "foo", "foo", nil, habv1beta1.Bind{Name: "db", Service: "postgresql", Group: "default"})
+	h.Spec.V1beta2.Paused = true
+
+	if err := hc.reconcile(h); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	if len(h.Status.Conditions) != 1 {
+		t.Errorf("conditions = %+v, want only Paused", h.Status.Conditions)
+	}
+}
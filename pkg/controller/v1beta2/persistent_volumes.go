@@ -0,0 +1,351 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	snapshotAPIGroup = "snapshot.storage.k8s.io"
+	pvcKind          = "PersistentVolumeClaim"
+	snapshotKind     = "VolumeSnapshot"
+)
+
+// persistentVolumes returns all the persistent volumes of hs. The volume
+// described by PersistentStorage comes first, named `persistent`.
+func persistentVolumes(hs *habv1beta1.V1beta2) []habv1beta1.PersistentVolume {
+	var volumes []habv1beta1.PersistentVolume
+	if ps := hs.PersistentStorage; ps != nil {
+		volumes = append(volumes, habv1beta1.PersistentVolume{
+			Name:              persistentVolumeName,
+			PersistentStorage: *ps,
+		})
+	}
+
+	return append(volumes, hs.PersistentVolumes...)
+}
+
+// newVolumeClaimTemplate returns the PersistentVolumeClaim template of the
+// persistent volume pv of the Habitat h.
+func newVolumeClaimTemplate(h *habv1beta1.Habitat, pv habv1beta1.PersistentVolume) (apiv1.PersistentVolumeClaim, error) {
+	q, err := resource.ParseQuantity(pv.Size)
+	if err != nil {
+		return apiv1.PersistentVolumeClaim{}, fmt.Errorf("Could not parse size of persistent volume %q: %v", pv.Name, err)
+	}
+
+	labels := map[string]string{}
+	for k, v := range pv.Labels {
+		labels[k] = v
+	}
+	labels[habv1beta1.HabitatLabel] = "true"
+	labels[habv1beta1.HabitatNameLabel] = h.Name
+
+	var annotations map[string]string
+	if len(pv.Annotations) > 0 {
+		annotations = map[string]string{}
+		for k, v := range pv.Annotations {
+			annotations[k] = v
+		}
+	}
+
+	accessModes := []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteOnce}
+	if len(pv.AccessModes) > 0 {
+		accessModes = append([]apiv1.PersistentVolumeAccessMode{}, pv.AccessModes...)
+	}
+
+	storageClassName := pv.StorageClassName
+
+	var volumeMode *apiv1.PersistentVolumeMode
+	if pv.VolumeMode != nil {
+		m := *pv.VolumeMode
+		volumeMode = &m
+	}
+
+	return apiv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pv.Name,
+			Namespace:   h.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: &storageClassName,
+			VolumeMode:       volumeMode,
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{
+					apiv1.ResourceStorage: q,
+				},
+			},
+		},
+	}, nil
+}
+
+// addPersistentVolumes adds the claim templates of the persistent volumes of
+// the Habitat h to spec, and mounts them in the service container.
+func addPersistentVolumes(h *habv1beta1.Habitat, spec *appsv1.StatefulSetSpec) error {
+	container := &spec.Template.Spec.Containers[0]
+
+	for _, pv := range persistentVolumes(h.Spec.V1beta2) {
+		claim, err := newVolumeClaimTemplate(h, pv)
+		if err != nil {
+			return err
+		}
+
+		spec.VolumeClaimTemplates = append(spec.VolumeClaimTemplates, claim)
+
+		if pv.VolumeMode != nil && *pv.VolumeMode == apiv1.PersistentVolumeBlock {
+			container.VolumeDevices = append(container.VolumeDevices, apiv1.VolumeDevice{
+				Name:       pv.Name,
+				DevicePath: pv.MountPath,
+			})
+			continue
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{
+			Name:      pv.Name,
+			MountPath: pv.MountPath,
+		})
+	}
+
+	return nil
+}
+
+// validatePersistentVolumes checks the persistent volumes of spec.
+func validatePersistentVolumes(spec *habv1beta1.V1beta2) error {
+	// These names are used by the other volumes of the Pods.
+	names := map[string]bool{
+		"config":               true,
+		userConfigFilename:     true,
+		filesDirectoryName:     true,
+		filesSourcesVolumeName: true,
+	}
+	if rsn := spec.Service.RingSecretName; rsn != nil {
+		names[*rsn] = true
+	}
+	paths := map[string]bool{}
+
+	for i, pv := range persistentVolumes(spec) {
+		// The name of PersistentStorage is fixed.
+		if i > 0 || spec.PersistentStorage == nil {
+			if errs := validation.IsDNS1123Label(pv.Name); len(errs) > 0 {
+				return fmt.Errorf("invalid persistent volume name %q: %s", pv.Name, strings.Join(errs, ", "))
+			}
+		}
+
+		if names[pv.Name] {
+			return fmt.Errorf("persistent volume name %q is already in use", pv.Name)
+		}
+		names[pv.Name] = true
+
+		if pv.MountPath == "" {
+			return fmt.Errorf("persistent volume %q must specify mountPath", pv.Name)
+		}
+		if paths[pv.MountPath] {
+			return fmt.Errorf("mountPath %q is used by more than one persistent volume", pv.MountPath)
+		}
+		paths[pv.MountPath] = true
+
+		if _, err := resource.ParseQuantity(pv.Size); err != nil {
+			return fmt.Errorf("invalid size of persistent volume %q: %v", pv.Name, err)
+		}
+
+		for _, m := range pv.AccessModes {
+			switch m {
+			case apiv1.ReadWriteOnce, apiv1.ReadOnlyMany, apiv1.ReadWriteMany:
+			default:
+				return fmt.Errorf("unknown access mode of persistent volume %q: %s", pv.Name, m)
+			}
+		}
+
+		if m := pv.VolumeMode; m != nil {
+			switch *m {
+			case apiv1.PersistentVolumeFilesystem, apiv1.PersistentVolumeBlock:
+			default:
+				return fmt.Errorf("unknown volume mode of persistent volume %q: %s", pv.Name, *m)
+			}
+		}
+
+		if ds := pv.DataSource; ds != nil {
+			if err := validateDataSource(ds); err != nil {
+				return fmt.Errorf("invalid dataSource of persistent volume %q: %v", pv.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateDataSource(ds *habv1beta1.VolumeDataSource) error {
+	if ds.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+
+	group := ""
+	if ds.APIGroup != nil {
+		group = *ds.APIGroup
+	}
+
+	switch {
+	case ds.Kind == pvcKind && group == "":
+	case ds.Kind == snapshotKind && group == snapshotAPIGroup:
+	default:
+		return fmt.Errorf("unsupported kind %q in API group %q", ds.Kind, group)
+	}
+
+	return nil
+}
+
+// claimName returns the name of the PersistentVolumeClaim the StatefulSet
+// controller binds to the volume of the Pod with the given ordinal.
+func claimName(template apiv1.PersistentVolumeClaim, sts *appsv1.StatefulSet, ordinal int) string {
+	return fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, ordinal)
+}
+
+// dataSourceClaim is a PersistentVolumeClaim populated from a data source.
+type dataSourceClaim struct {
+	claim      *apiv1.PersistentVolumeClaim
+	dataSource *habv1beta1.VolumeDataSource
+}
+
+// dataSourceClaims returns the PersistentVolumeClaims of the volumes of the
+// Habitat h which are populated from a data source, and are to be created for
+// the StatefulSet sts. Only the claims of the replicas which the existing
+// StatefulSet doesn't have yet are returned.
+func (hc *HabitatController) dataSourceClaims(h *habv1beta1.Habitat, sts *appsv1.StatefulSet) ([]dataSourceClaim, error) {
+	var oldSts *appsv1.StatefulSet
+	if hc.stsInformer != nil {
+		obj, exists, err := hc.stsInformer.GetStore().GetByKey(habitatKey(h))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			var ok bool
+			if oldSts, ok = obj.(*appsv1.StatefulSet); !ok {
+				return nil, fmt.Errorf("unknown object type in StatefulSet cache: %v", obj)
+			}
+		}
+	}
+
+	var claims []dataSourceClaim
+	for i, pv := range persistentVolumes(h.Spec.V1beta2) {
+		if pv.DataSource == nil {
+			continue
+		}
+
+		template := sts.Spec.VolumeClaimTemplates[i]
+
+		for ordinal := claimedReplicas(oldSts, template.Name); ordinal < h.Spec.V1beta2.Count; ordinal++ {
+			claim := template.DeepCopy()
+			claim.Name = claimName(template, sts, ordinal)
+			claim.Namespace = h.Namespace
+			claim.Kind = pvcKind
+			claim.APIVersion = apiv1.SchemeGroupVersion.String()
+
+			claims = append(claims, dataSourceClaim{claim: claim, dataSource: pv.DataSource})
+		}
+	}
+
+	return claims, nil
+}
+
+// createDataSourceClaims creates the PersistentVolumeClaims of the volumes of
+// the Habitat h which are populated from a data source, before the
+// StatefulSet controller creates them without one. The vendored Kubernetes
+// API predates the `dataSource` field of PersistentVolumeClaims, so the claims
+// are sent as raw JSON.
+func (hc *HabitatController) createDataSourceClaims(h *habv1beta1.Habitat, sts *appsv1.StatefulSet) error {
+	claims, err := hc.dataSourceClaims(h, sts)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range claims {
+		body, err := claimWithDataSource(c.claim, c.dataSource)
+		if err != nil {
+			return err
+		}
+
+		err = hc.config.KubernetesClientset.CoreV1().RESTClient().Post().
+			Namespace(h.Namespace).
+			Resource("persistentvolumeclaims").
+			Body(body).
+			Do().
+			Error()
+		if err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", "created PersistentVolumeClaim from data source", "name", c.claim.Name)
+	}
+
+	return nil
+}
+
+// claimedReplicas returns the number of replicas of the StatefulSet sts whose
+// claims from the template named template were created along with them. It's
+// 0 if sts is nil or has no such template.
+func claimedReplicas(sts *appsv1.StatefulSet, template string) int {
+	if sts == nil {
+		return 0
+	}
+
+	for _, t := range sts.Spec.VolumeClaimTemplates {
+		if t.Name == template {
+			return replicaCount(sts, 0)
+		}
+	}
+
+	return 0
+}
+
+// claimWithDataSource returns the JSON representation of claim, with its
+// data source set to ds.
+func claimWithDataSource(claim *apiv1.PersistentVolumeClaim, ds *habv1beta1.VolumeDataSource) ([]byte, error) {
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	spec, ok := obj["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("PersistentVolumeClaim %q has no spec", claim.Name)
+	}
+	spec["dataSource"] = ds
+
+	return json.Marshal(obj)
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestAddPersistentVolumes(t *testing.T) {
+	block := apiv1.PersistentVolumeBlock
+
+	h := newTestHabitat("myproject", "db", "postgresql", nil)
+	h.Spec.V1beta2.PersistentStorage = &habv1beta1.PersistentStorage{
+		Size:             "1Gi",
+		MountPath:        "/hab/svc/postgresql/data",
+		StorageClassName: "standard",
+	}
+	h.Spec.V1beta2.PersistentVolumes = []habv1beta1.PersistentVolume{
+		{
+			Name: "wal",
+			PersistentStorage: habv1beta1.PersistentStorage{
+				Size:             "10Gi",
+				MountPath:        "/dev/wal",
+				StorageClassName: "fast",
+				AccessModes:      []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteMany},
+				VolumeMode:       &block,
+				Labels:           map[string]string{"tier": "fast", habv1beta1.HabitatNameLabel: "other"},
+				Annotations:      map[string]string{"backup": "daily"},
+			},
+		},
+	}
+
+	spec := &appsv1.StatefulSetSpec{
+		Template: apiv1.PodTemplateSpec{
+			Spec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{Name: "habitat-service"}},
+			},
+		},
+	}
+	if err := addPersistentVolumes(h, spec); err != nil {
+		t.Fatalf("addPersistentVolumes() error = %v", err)
+	}
+
+	if n := len(spec.VolumeClaimTemplates); n != 2 {
+		t.Fatalf("got %d claim templates, want 2", n)
+	}
+
+	persistent := spec.VolumeClaimTemplates[0]
+	if persistent.Name != persistentVolumeName || !reflect.DeepEqual(persistent.Spec.AccessModes, []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteOnce}) {
+		t.Errorf("persistent claim template = %+v", persistent)
+	}
+
+	wal := spec.VolumeClaimTemplates[1]
+	wantLabels := map[string]string{
+		"tier":                      "fast",
+		habv1beta1.HabitatLabel:     "true",
+		habv1beta1.HabitatNameLabel: "db",
+	}
+	if !reflect.DeepEqual(wal.Labels, wantLabels) {
+		t.Errorf("wal claim labels = %v, want %v", wal.Labels, wantLabels)
+	}
+	if wal.Annotations["backup"] != "daily" || *wal.Spec.VolumeMode != block || *wal.Spec.StorageClassName != "fast" {
+		t.Errorf("wal claim template = %+v", wal)
+	}
+
+	container := spec.Template.Spec.Containers[0]
+	wantMounts := []apiv1.VolumeMount{{Name: persistentVolumeName, MountPath: "/hab/svc/postgresql/data"}}
+	if !reflect.DeepEqual(container.VolumeMounts, wantMounts) {
+		t.Errorf("volume mounts = %v, want %v", container.VolumeMounts, wantMounts)
+	}
+	wantDevices := []apiv1.VolumeDevice{{Name: "wal", DevicePath: "/dev/wal"}}
+	if !reflect.DeepEqual(container.VolumeDevices, wantDevices) {
+		t.Errorf("volume devices = %v, want %v", container.VolumeDevices, wantDevices)
+	}
+}
+
+func TestValidatePersistentVolumes(t *testing.T) {
+	volume := func(name, path string) habv1beta1.PersistentVolume {
+		return habv1beta1.PersistentVolume{
+			Name:              name,
+			PersistentStorage: habv1beta1.PersistentStorage{Size: "1Gi", MountPath: path},
+		}
+	}
+	snapshotGroup := snapshotAPIGroup
+
+	tests := []struct {
+		name    string
+		volumes []habv1beta1.PersistentVolume
+		wantErr bool
+	}{
+		{
+			name:    "valid volumes",
+			volumes: []habv1beta1.PersistentVolume{volume("data", "/data"), volume("logs", "/logs")},
+		},
+		{
+			name:    "duplicate name",
+			volumes: []habv1beta1.PersistentVolume{volume("data", "/data"), volume("data", "/logs")},
+			wantErr: true,
+		},
+		{
+			name:    "reserved name",
+			volumes: []habv1beta1.PersistentVolume{volume("config", "/data")},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate mount path",
+			volumes: []habv1beta1.PersistentVolume{volume("data", "/data"), volume("logs", "/data")},
+			wantErr: true,
+		},
+		{
+			name: "snapshot data source",
+			volumes: []habv1beta1.PersistentVolume{
+				func() habv1beta1.PersistentVolume {
+					v := volume("data", "/data")
+					v.DataSource = &habv1beta1.VolumeDataSource{APIGroup: &snapshotGroup, Kind: "VolumeSnapshot", Name: "nightly"}
+					return v
+				}(),
+			},
+		},
+		{
+			name: "unsupported data source",
+			volumes: []habv1beta1.PersistentVolume{
+				func() habv1beta1.PersistentVolume {
+					v := volume("data", "/data")
+					v.DataSource = &habv1beta1.VolumeDataSource{Kind: "Secret", Name: "nightly"}
+					return v
+				}(),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePersistentVolumes(&habv1beta1.V1beta2{PersistentVolumes: tt.volumes})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePersistentVolumes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaimWithDataSource(t *testing.T) {
+	claim := &apiv1.PersistentVolumeClaim{}
+	claim.Name = "data-db-0"
+
+	body, err := claimWithDataSource(claim, &habv1beta1.VolumeDataSource{Kind: "PersistentVolumeClaim", Name: "data-old-0"})
+	if err != nil {
+		t.Fatalf("claimWithDataSource() error = %v", err)
+	}
+
+	var obj struct {
+		Spec struct {
+			DataSource map[string]string `json:"dataSource"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		t.Fatalf("could not decode claim: %v", err)
+	}
+
+	want := map[string]string{"kind": "PersistentVolumeClaim", "name": "data-old-0"}
+	if !reflect.DeepEqual(obj.Spec.DataSource, want) {
+		t.Errorf("claimWithDataSource() dataSource = %v, want %v", obj.Spec.DataSource, want)
+	}
+}
+
+func TestClaimedReplicas(t *testing.T) {
+	replicas := int32(3)
+	sts := &appsv1.StatefulSet{}
+	sts.Spec.Replicas = &replicas
+	sts.Spec.VolumeClaimTemplates = []apiv1.PersistentVolumeClaim{{}}
+	sts.Spec.VolumeClaimTemplates[0].Name = "data"
+
+	if got := claimedReplicas(nil, "data"); got != 0 {
+		t.Errorf("claimedReplicas() without a StatefulSet = %d, want 0", got)
+	}
+	// The claims of the existing replicas aren't created again.
+	if got := claimedReplicas(sts, "data"); got != 3 {
+		t.Errorf("claimedReplicas() = %d, want 3", got)
+	}
+	// The claims of a new volume are created for all replicas.
+	if got := claimedReplicas(sts, "logs"); got != 0 {
+		t.Errorf("claimedReplicas() of a new template = %d, want 0", got)
+	}
+}
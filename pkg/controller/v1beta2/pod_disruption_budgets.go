@@ -0,0 +1,241 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"reflect"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// pdbEnabled returns true if the Habitat h needs a PodDisruptionBudget.
+func pdbEnabled(h *habv1beta1.Habitat) bool {
+	pdb := h.Spec.V1beta2.PodDisruptionBudget
+	return pdb == nil || !pdb.Disabled
+}
+
+// newPodDisruptionBudget returns the PodDisruptionBudget of the Habitat h.
+func newPodDisruptionBudget(h *habv1beta1.Habitat) *policyv1beta1.PodDisruptionBudget {
+	hs := h.Spec.V1beta2
+
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				habv1beta1.HabitatNameLabel: h.Name,
+			},
+		},
+	}
+
+	switch pdb := hs.PodDisruptionBudget; {
+	case pdb != nil && pdb.MinAvailable != nil:
+		v := *pdb.MinAvailable
+		spec.MinAvailable = &v
+	case pdb != nil && pdb.MaxUnavailable != nil:
+		v := *pdb.MaxUnavailable
+		spec.MaxUnavailable = &v
+	case hs.Service.Topology == habv1beta1.TopologyLeader:
+		// Losing the quorum prevents the election of a leader. With fewer than
+		// 3 Pods there is no quorum to keep, and requiring all of them to be
+		// available would block node drains.
+		min := hs.Count/2 + 1
+		if min >= hs.Count {
+			min = hs.Count - 1
+		}
+		if min < 0 {
+			min = 0
+		}
+		v := intstr.FromInt(min)
+		spec.MinAvailable = &v
+	default:
+		v := intstr.FromInt(1)
+		spec.MaxUnavailable = &v
+	}
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.Name,
+			Namespace: h.Namespace,
+			Labels: map[string]string{
+				habv1beta1.HabitatLabel:     "true",
+				habv1beta1.HabitatNameLabel: h.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				metav1.OwnerReference{
+					APIVersion: habv1beta1.SchemeGroupVersion.String(),
+					Kind:       habv1beta1.HabitatKind,
+					Name:       h.Name,
+					UID:        h.UID,
+				},
+			},
+		},
+		Spec: spec,
+	}
+}
+
+// validatePodDisruptionBudget checks the PodDisruptionBudget settings of spec.
+func validatePodDisruptionBudget(spec *habv1beta1.V1beta2) error {
+	pdb := spec.PodDisruptionBudget
+	if pdb == nil {
+		return nil
+	}
+
+	if pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		return fmt.Errorf("podDisruptionBudget can't specify both minAvailable and maxUnavailable")
+	}
+
+	for _, v := range []*intstr.IntOrString{pdb.MinAvailable, pdb.MaxUnavailable} {
+		if v == nil {
+			continue
+		}
+
+		if _, err := intstr.GetValueFromIntOrPercent(v, spec.Count, true); err != nil {
+			return fmt.Errorf("invalid podDisruptionBudget: %v", err)
+		}
+
+		if v.Type == intstr.Int && v.IntVal < 0 {
+			return fmt.Errorf("invalid podDisruptionBudget: negative value %d", v.IntVal)
+		}
+	}
+
+	return nil
+}
+
+// handlePodDisruptionBudget creates, updates or deletes the
+// PodDisruptionBudget of the Habitat h, depending on its spec.
+func (hc *HabitatController) handlePodDisruptionBudget(h *habv1beta1.Habitat) error {
+	obj, exists, err := hc.pdbInformer.GetStore().GetByKey(habitatKey(h))
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget)
+		if !ok {
+			return fmt.Errorf("unknown object type in PodDisruptionBudget cache: %v", obj)
+		}
+
+		// PodDisruptionBudgets created by users with the same name are left
+		// alone.
+		if !ownedByHabitat(pdb, h) {
+			hc.recorder.Eventf(h, apiv1.EventTypeWarning, resourceConflict, messageResourceConflict, "PodDisruptionBudget", pdb.Name)
+			return nil
+		}
+
+		if pdbEnabled(h) && reflect.DeepEqual(pdb.Spec, newPodDisruptionBudget(h).Spec) {
+			return nil
+		}
+
+		// The spec of a PodDisruptionBudget can't be updated, so it has to be
+		// recreated.
+		err := hc.config.KubernetesClientset.PolicyV1beta1().PodDisruptionBudgets(h.Namespace).Delete(pdb.Name, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &pdb.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", "deleted PodDisruptionBudget", "name", pdb.Name)
+	}
+
+	if !pdbEnabled(h) {
+		return nil
+	}
+
+	newPDB := newPodDisruptionBudget(h)
+	if _, err := hc.config.KubernetesClientset.PolicyV1beta1().PodDisruptionBudgets(h.Namespace).Create(newPDB); err != nil {
+		// The cache might not have caught up with a previous creation yet.
+		if apierrors.IsAlreadyExists(err) {
+			level.Debug(hc.logger).Log("msg", "PodDisruptionBudget already existed", "name", newPDB.Name)
+			return nil
+		}
+
+		return err
+	}
+
+	level.Info(hc.logger).Log("msg", "created PodDisruptionBudget", "name", newPDB.Name)
+	hc.recorder.Event(h, apiv1.EventTypeNormal, pdbCreated, messagePdbCreated)
+
+	return nil
+}
+
+func (hc *HabitatController) cachePodDisruptionBudgets() {
+	hc.pdbInformer = hc.kubeInformer(func(f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Policy().V1beta1().PodDisruptionBudgets().Informer()
+	})
+
+	hc.pdbInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    hc.handlePdb,
+		UpdateFunc: hc.handlePdbUpdate,
+		DeleteFunc: hc.handlePdb,
+	})
+
+	hc.pdbInformerSynced = hc.pdbInformer.HasSynced
+}
+
+func (hc *HabitatController) handlePdb(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert PodDisruptionBudget", "obj", obj)
+		return
+	}
+
+	if !isHabitatObject(&pdb.ObjectMeta) {
+		return
+	}
+
+	h, err := hc.getHabitatFromLabeledResource(pdb)
+	if err != nil {
+		// Could not find Habitat, it must have already been removed.
+		level.Debug(hc.logger).Log("msg", "Could not find Habitat for PodDisruptionBudget", "name", pdb.Name)
+		return
+	}
+
+	hc.enqueue(h)
+}
+
+func (hc *HabitatController) handlePdbUpdate(oldObj, newObj interface{}) {
+	oldPDB, ok := oldObj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert PodDisruptionBudget", "obj", oldObj)
+		return
+	}
+
+	newPDB, ok := newObj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert PodDisruptionBudget", "obj", newObj)
+		return
+	}
+
+	// Status updates don't need a reconciliation.
+	if reflect.DeepEqual(oldPDB.Spec, newPDB.Spec) {
+		return
+	}
+
+	hc.handlePdb(newPDB)
+}
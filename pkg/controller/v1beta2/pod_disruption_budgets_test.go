@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestNewPodDisruptionBudget(t *testing.T) {
+	intOrStr := func(v intstr.IntOrString) *intstr.IntOrString {
+		return &v
+	}
+
+	tests := []struct {
+		name               string
+		topology           habv1beta1.Topology
+		count              int
+		pdb                *habv1beta1.PodDisruptionBudget
+		wantMinAvailable   *intstr.IntOrString
+		wantMaxUnavailable *intstr.IntOrString
+	}{
+		{
+			name:             "leader topology keeps a quorum",
+			topology:         habv1beta1.TopologyLeader,
+			count:            5,
+			wantMinAvailable: intOrStr(intstr.FromInt(3)),
+		},
+		{
+			name:             "leader topology with too few Pods for a quorum",
+			topology:         habv1beta1.TopologyLeader,
+			count:            2,
+			wantMinAvailable: intOrStr(intstr.FromInt(1)),
+		},
+		{
+			name:             "leader topology with a single Pod",
+			topology:         habv1beta1.TopologyLeader,
+			count:            1,
+			wantMinAvailable: intOrStr(intstr.FromInt(0)),
+		},
+		{
+			name:               "standalone topology defaults to one unavailable Pod",
+			topology:           habv1beta1.TopologyStandalone,
+			count:              5,
+			wantMaxUnavailable: intOrStr(intstr.FromInt(1)),
+		},
+		{
+			name:     "explicit setting",
+			topology: habv1beta1.TopologyStandalone,
+			count:    4,
+			pdb: &habv1beta1.PodDisruptionBudget{
+				MinAvailable: intOrStr(intstr.FromString("50%")),
+			},
+			wantMinAvailable: intOrStr(intstr.FromString("50%")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHabitat("myproject", "db", "postgresql", nil)
+			h.Spec.V1beta2.Count = tt.count
+			h.Spec.V1beta2.Service.Topology = tt.topology
+			h.Spec.V1beta2.PodDisruptionBudget = tt.pdb
+
+			spec := newPodDisruptionBudget(h).Spec
+			if !reflect.DeepEqual(spec.MinAvailable, tt.wantMinAvailable) {
+				t.Errorf("minAvailable = %v, want %v", spec.MinAvailable, tt.wantMinAvailable)
+			}
+			if !reflect.DeepEqual(spec.MaxUnavailable, tt.wantMaxUnavailable) {
+				t.Errorf("maxUnavailable = %v, want %v", spec.MaxUnavailable, tt.wantMaxUnavailable)
+			}
+		})
+	}
+}
+
+func TestValidatePodDisruptionBudget(t *testing.T) {
+	one := intstr.FromInt(1)
+	badPercent := intstr.FromString("half")
+
+	tests := []struct {
+		name    string
+		pdb     *habv1beta1.PodDisruptionBudget
+		wantErr bool
+	}{
+		{name: "not set"},
+		{name: "min available", pdb: &habv1beta1.PodDisruptionBudget{MinAvailable: &one}},
+		{name: "both set", pdb: &habv1beta1.PodDisruptionBudget{MinAvailable: &one, MaxUnavailable: &one}, wantErr: true},
+		{name: "invalid percentage", pdb: &habv1beta1.PodDisruptionBudget{MaxUnavailable: &badPercent}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePodDisruptionBudget(&habv1beta1.V1beta2{Count: 3, PodDisruptionBudget: tt.pdb})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePodDisruptionBudget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The controller has no clientset, so deleting the PodDisruptionBudget panics.
+func TestHandlePodDisruptionBudgetNotOwned(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	hc := &HabitatController{
+		recorder:    recorder,
+		pdbInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &policyv1beta1.PodDisruptionBudget{}, 0, cache.Indexers{}),
+	}
+
+	h := newTestHabitat("myproject", "db", "postgresql", nil)
+	h.UID = types.UID("db-uid")
+
+	// A PodDisruptionBudget created by a user, with a different spec.
+	pdb := newPodDisruptionBudget(h)
+	pdb.OwnerReferences = nil
+	one := intstr.FromInt(1)
+	pdb.Spec.MaxUnavailable, pdb.Spec.MinAvailable = nil, &one
+	if err := hc.pdbInformer.GetIndexer().Add(pdb); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hc.handlePodDisruptionBudget(h); err != nil {
+		t.Fatalf("handlePodDisruptionBudget() error = %v", err)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("recorded %d events, want a conflict", len(recorder.Events))
+	}
+}
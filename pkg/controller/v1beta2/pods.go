@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podHabitatIndex is the name of the index on the Pod informer mapping the
+// namespaced names of Habitats to their Pods.
+const podHabitatIndex = "habitat"
+
+func podHabitatIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("unknown object type in Pod cache: %v", obj)
+	}
+
+	name := pod.Labels[habv1beta1.HabitatNameLabel]
+	if name == "" {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("%s/%s", pod.Namespace, name)}, nil
+}
+
+// podsInformer returns the informer of the Pods of Habitats in the watched
+// namespaces. It's shared through the informer factories, but only lists the
+// Pods labeled as belonging to Habitats.
+func (hc *HabitatController) podsInformer() cache.SharedIndexInformer {
+	informerFor := func(namespace string, f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.InformerFor(&apiv1.Pod{}, func(client kubernetes.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+			return coreinformers.NewFilteredPodInformer(
+				client,
+				namespace,
+				resyncPeriod,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+				listOptions())
+		})
+	}
+
+	if len(hc.config.Namespaces) == 0 {
+		return informerFor(hc.config.Namespace, hc.config.KubeInformerFactory)
+	}
+
+	informers := map[string]cache.SharedIndexInformer{}
+	for ns, f := range hc.config.Namespaces {
+		informers[ns] = informerFor(ns, f.KubeInformerFactory)
+	}
+
+	return newMultiNamespaceInformer(informers)
+}
+
+// runningPods returns the running Pods among objs, sorted by name.
+func runningPods(objs []interface{}) []apiv1.Pod {
+	var running []apiv1.Pod
+	for _, obj := range objs {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok || pod.Status.Phase != apiv1.PodRunning {
+			continue
+		}
+
+		running = append(running, *pod)
+	}
+
+	sort.Slice(running, func(i, j int) bool { return running[i].Name < running[j].Name })
+
+	return running
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestPod(habitat, name string, phase apiv1.PodPhase) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				habv1beta1.HabitatLabel:     "true",
+				habv1beta1.HabitatNameLabel: habitat,
+			},
+		},
+		Status: apiv1.PodStatus{Phase: phase},
+	}
+}
+
+func TestGetRunningPods(t *testing.T) {
+	hc := &HabitatController{
+		podInformer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &apiv1.Pod{}, 0, cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			podHabitatIndex:      podHabitatIndexFunc,
+		}),
+	}
+
+	for _, p := range []*apiv1.Pod{
+		newTestPod("db", "db-1", apiv1.PodRunning),
+		newTestPod("db", "db-0", apiv1.PodRunning),
+		newTestPod("web", "web-1", apiv1.PodPending),
+		newTestPod("web", "web-0", apiv1.PodRunning),
+		newTestPod("cache", "cache-0", apiv1.PodRunning),
+	} {
+		if err := hc.podInformer.GetIndexer().Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pods, err := hc.getRunningPods("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range pods {
+		names = append(names, p.Name)
+	}
+
+	if want := []string{"cache-0", "db-0", "db-1", "web-0"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("getRunningPods() = %v, want %v", names, want)
+	}
+}
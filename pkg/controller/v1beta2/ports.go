@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// Names of the ports of the supervisor.
+	gossipPortName    = "gossip"
+	gossipUDPPortName = "gossip-udp"
+	httpPortName      = "http"
+
+	// Default ports of the supervisor.
+	defaultGossipPort = 9638
+	defaultHTTPPort   = 9631
+)
+
+// listenPort returns the port of the listen address addr, or def if addr is
+// not set. The address must have been validated beforehand.
+func listenPort(addr *string, def int32) int32 {
+	if addr == nil {
+		return def
+	}
+
+	_, port, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return def
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return def
+	}
+
+	return int32(p)
+}
+
+// gossipPort returns the port the supervisor gossips on.
+func gossipPort(hs *habv1beta1.V1beta2) int32 {
+	if hs.Supervisor == nil {
+		return defaultGossipPort
+	}
+
+	return listenPort(hs.Supervisor.ListenGossip, defaultGossipPort)
+}
+
+// httpPort returns the port of the supervisor's HTTP gateway.
+func httpPort(hs *habv1beta1.V1beta2) int32 {
+	if hs.Supervisor == nil {
+		return defaultHTTPPort
+	}
+
+	return listenPort(hs.Supervisor.ListenHTTP, defaultHTTPPort)
+}
+
+// supervisorPorts returns the ports of the supervisor.
+func supervisorPorts(hs *habv1beta1.V1beta2) []apiv1.ContainerPort {
+	return []apiv1.ContainerPort{
+		{
+			Name:          gossipPortName,
+			ContainerPort: gossipPort(hs),
+			Protocol:      apiv1.ProtocolTCP,
+		},
+		{
+			Name:          gossipUDPPortName,
+			ContainerPort: gossipPort(hs),
+			Protocol:      apiv1.ProtocolUDP,
+		},
+		{
+			Name:          httpPortName,
+			ContainerPort: httpPort(hs),
+			Protocol:      apiv1.ProtocolTCP,
+		},
+	}
+}
+
+// containerPorts returns the ports of the supervisor, followed by the ports of
+// the service.
+func containerPorts(hs *habv1beta1.V1beta2) []apiv1.ContainerPort {
+	ports := supervisorPorts(hs)
+
+	for _, p := range hs.Service.Ports {
+		if p.Protocol == "" {
+			p.Protocol = apiv1.ProtocolTCP
+		}
+
+		ports = append(ports, p)
+	}
+
+	return ports
+}
+
+// validatePorts checks that the service ports of spec are valid, and don't
+// conflict with each other or with the ports of the supervisor.
+func validatePorts(spec *habv1beta1.V1beta2) error {
+	names := map[string]bool{}
+	numbers := map[string]bool{}
+
+	// The supervisor ports are validated along with the listen addresses.
+	sup := supervisorPorts(spec)
+	for _, p := range sup {
+		number := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		if numbers[number] {
+			return fmt.Errorf("the gossip and HTTP ports of the supervisor must differ: %s", number)
+		}
+
+		names[p.Name] = true
+		numbers[number] = true
+	}
+
+	for _, p := range containerPorts(spec)[len(sup):] {
+		if errs := validation.IsValidPortNum(int(p.ContainerPort)); len(errs) > 0 {
+			return fmt.Errorf("invalid port %d: %s", p.ContainerPort, strings.Join(errs, ", "))
+		}
+
+		switch p.Protocol {
+		case apiv1.ProtocolTCP, apiv1.ProtocolUDP:
+		default:
+			return fmt.Errorf("unknown protocol of port %d: %s", p.ContainerPort, p.Protocol)
+		}
+
+		if p.Name != "" {
+			if errs := validation.IsValidPortName(p.Name); len(errs) > 0 {
+				return fmt.Errorf("invalid port name %q: %s", p.Name, strings.Join(errs, ", "))
+			}
+
+			if names[p.Name] {
+				return fmt.Errorf("port name %q is used more than once", p.Name)
+			}
+			names[p.Name] = true
+		}
+
+		number := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		if numbers[number] {
+			return fmt.Errorf("port %s is used more than once", number)
+		}
+		numbers[number] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestContainerPorts(t *testing.T) {
+	hs := &habv1beta1.V1beta2{
+		Service: habv1beta1.ServiceV1beta2{
+			Ports: []apiv1.ContainerPort{
+				{Name: "redis", ContainerPort: 6379},
+			},
+		},
+		Supervisor: &habv1beta1.Supervisor{
+			ListenGossip: strToPtr("0.0.0.0:9000"),
+		},
+	}
+
+	want := []apiv1.ContainerPort{
+		{Name: "gossip", ContainerPort: 9000, Protocol: apiv1.ProtocolTCP},
+		{Name: "gossip-udp", ContainerPort: 9000, Protocol: apiv1.ProtocolUDP},
+		{Name: "http", ContainerPort: 9631, Protocol: apiv1.ProtocolTCP},
+		{Name: "redis", ContainerPort: 6379, Protocol: apiv1.ProtocolTCP},
+	}
+	if got := containerPorts(hs); !reflect.DeepEqual(got, want) {
+		t.Errorf("containerPorts() = %v, want %v", got, want)
+	}
+
+	// The spec must not be modified.
+	if hs.Service.Ports[0].Protocol != "" {
+		t.Errorf("containerPorts() modified the spec: %v", hs.Service.Ports)
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   []apiv1.ContainerPort
+		wantErr bool
+	}{
+		{
+			name:  "valid ports",
+			ports: []apiv1.ContainerPort{{Name: "redis", ContainerPort: 6379}, {ContainerPort: 6380}},
+		},
+		{
+			name:  "supervisor port number with another protocol",
+			ports: []apiv1.ContainerPort{{Name: "http-udp", ContainerPort: 9631, Protocol: apiv1.ProtocolUDP}},
+		},
+		{
+			name:    "reserved port name",
+			ports:   []apiv1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			wantErr: true,
+		},
+		{
+			name:    "supervisor port number",
+			ports:   []apiv1.ContainerPort{{Name: "web", ContainerPort: 9631}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port name",
+			ports:   []apiv1.ContainerPort{{Name: "Not_A_Name", ContainerPort: 8080}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port number",
+			ports:   []apiv1.ContainerPort{{Name: "web", ContainerPort: 0}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &habv1beta1.V1beta2{Service: habv1beta1.ServiceV1beta2{Ports: tt.ports}}
+			err := validatePorts(spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// Names of the indexes on the Habitat informer, mapping Secrets and
+	// ConfigMaps to the Habitats referencing them.
+	secretRefIndex    = "secretRef"
+	configMapRefIndex = "configMapRef"
+
+	// configHashAnnotation is set on the Pod template, and contains a hash of
+	// the contents of all the Secrets and ConfigMaps mounted in the Pods.
+	// Whenever the contents change, so does the template, which causes the Pods
+	// to be updated.
+	configHashAnnotation = "operator.habitat.sh/config-hash"
+)
+
+// secretRefs returns the names of all the Secrets referenced by the Habitat h.
+func secretRefs(h *habv1beta1.Habitat) []string {
+	hs := h.Spec.V1beta2
+	if hs == nil {
+		return nil
+	}
+
+	var names []string
+	for _, n := range []*string{hs.Service.ConfigSecretName, hs.Service.FilesSecretName, hs.Service.RingSecretName} {
+		if n != nil {
+			names = append(names, *n)
+		}
+	}
+
+	for _, s := range hs.Service.FilesSources {
+		if s.Secret != nil {
+			names = append(names, s.Secret.Name)
+		}
+	}
+
+	return names
+}
+
+// configMapRefs returns the names of all the ConfigMaps referenced by the
+// Habitat h.
+func configMapRefs(h *habv1beta1.Habitat) []string {
+	hs := h.Spec.V1beta2
+	if hs == nil {
+		return nil
+	}
+
+	var names []string
+	for _, ref := range []*apiv1.LocalObjectReference{hs.Service.ConfigMapRef, hs.Service.FilesConfigMapRef} {
+		if ref != nil {
+			names = append(names, ref.Name)
+		}
+	}
+
+	for _, s := range hs.Service.FilesSources {
+		if s.ConfigMap != nil {
+			names = append(names, s.ConfigMap.Name)
+		}
+	}
+
+	return names
+}
+
+// refIndexFunc returns a cache.IndexFunc indexing Habitats by the
+// namespaced names of the objects returned by refs.
+func refIndexFunc(refs func(*habv1beta1.Habitat) []string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		h, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			return nil, fmt.Errorf("unknown object type in Habitat cache: %v", obj)
+		}
+
+		var keys []string
+		for _, name := range refs(h) {
+			keys = append(keys, fmt.Sprintf("%s/%s", h.Namespace, name))
+		}
+
+		return keys, nil
+	}
+}
+
+func (hc *HabitatController) cacheSecrets() {
+	hc.secretInformer = hc.kubeInformer(func(f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Secrets().Informer()
+	})
+
+	hc.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    hc.handleSecretAdd,
+		UpdateFunc: hc.handleSecretUpdate,
+		DeleteFunc: hc.handleSecretDelete,
+	})
+
+	hc.secretInformerSynced = hc.secretInformer.HasSynced
+}
+
+func (hc *HabitatController) handleSecret(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	s, ok := obj.(*apiv1.Secret)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert Secret", "obj", obj)
+		return
+	}
+
+	hc.enqueueReferencing(secretRefIndex, s.Namespace, s.Name)
+}
+
+func (hc *HabitatController) handleSecretAdd(obj interface{}) {
+	hc.handleSecret(obj)
+}
+
+func (hc *HabitatController) handleSecretUpdate(oldObj, newObj interface{}) {
+	oldSecret, ok := oldObj.(*apiv1.Secret)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert Secret", "obj", oldObj)
+		return
+	}
+
+	newSecret, ok := newObj.(*apiv1.Secret)
+	if !ok {
+		level.Error(hc.logger).Log("msg", "Failed to type assert Secret", "obj", newObj)
+		return
+	}
+
+	if oldSecret.ResourceVersion == newSecret.ResourceVersion {
+		return
+	}
+
+	hc.handleSecret(newObj)
+}
+
+func (hc *HabitatController) handleSecretDelete(obj interface{}) {
+	hc.handleSecret(obj)
+}
+
+// enqueueReferencing enqueues all the Habitats that reference the object
+// namespace/name, according to the Habitat informer index named index.
+func (hc *HabitatController) enqueueReferencing(index, namespace, name string) {
+	objs, err := hc.habInformer.GetIndexer().ByIndex(index, fmt.Sprintf("%s/%s", namespace, name))
+	if err != nil {
+		level.Error(hc.logger).Log("msg", "Failed to look up referencing Habitats", "err", err, "index", index)
+		return
+	}
+
+	for _, obj := range objs {
+		h, ok := obj.(*habv1beta1.Habitat)
+		if !ok {
+			level.Error(hc.logger).Log("msg", "Failed to type assert Habitat", "obj", obj)
+			continue
+		}
+
+		hc.enqueue(h)
+	}
+}
+
+// contentHasher computes a hash over the contents of a set of Secrets and
+// ConfigMaps, independently of the order in which they are added.
+type contentHasher struct {
+	contents map[string]map[string][]byte
+}
+
+func newContentHasher() *contentHasher {
+	return &contentHasher{contents: map[string]map[string][]byte{}}
+}
+
+func (c *contentHasher) addSecret(s *apiv1.Secret) {
+	c.contents[fmt.Sprintf("secret/%s", s.Name)] = s.Data
+}
+
+func (c *contentHasher) addConfigMap(cm *apiv1.ConfigMap) {
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+
+	c.contents[fmt.Sprintf("configmap/%s", cm.Name)] = data
+}
+
+// sum returns the hash of the contents added so far, or an empty string if
+// nothing was added.
+func (c *contentHasher) sum() string {
+	if len(c.contents) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, obj := range sortedKeys(c.contents) {
+		data := c.contents[obj]
+
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(h, "%s\n", obj)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s\n%d\n", k, len(data[k]))
+			h.Write(data[k])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sortedKeys(m map[string]map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRefIndexFunc(t *testing.T) {
+	h := &habv1beta1.Habitat{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db",
+			Namespace: "myproject",
+		},
+		Spec: habv1beta1.HabitatSpec{
+			V1beta2: &habv1beta1.V1beta2{
+				Service: habv1beta1.ServiceV1beta2{
+					ConfigSecretName: strToPtr("user-toml"),
+					RingSecretName:   strToPtr("ring-20180101000000"),
+					ConfigMapRef:     &apiv1.LocalObjectReference{Name: "config"},
+					FilesSources: []apiv1.VolumeProjection{
+						{Secret: &apiv1.SecretProjection{LocalObjectReference: apiv1.LocalObjectReference{Name: "tls"}}},
+						{ConfigMap: &apiv1.ConfigMapProjection{LocalObjectReference: apiv1.LocalObjectReference{Name: "motd"}}},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		refs func(*habv1beta1.Habitat) []string
+		want []string
+	}{
+		{
+			name: "secrets",
+			refs: secretRefs,
+			want: []string{"myproject/user-toml", "myproject/ring-20180101000000", "myproject/tls"},
+		},
+		{
+			name: "config maps",
+			refs: configMapRefs,
+			want: []string{"myproject/config", "myproject/motd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := refIndexFunc(tt.refs)(h)
+			if err != nil {
+				t.Fatalf("refIndexFunc() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("refIndexFunc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentHasher(t *testing.T) {
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-toml"},
+		Data:       map[string][]byte{userTOMLFile: []byte("port = 6999")},
+	}
+	cm := &apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "files"},
+		Data:       map[string]string{"motd": "hello"},
+	}
+
+	if sum := newContentHasher().sum(); sum != "" {
+		t.Errorf("sum() of no objects = %q, want empty string", sum)
+	}
+
+	h1 := newContentHasher()
+	h1.addSecret(secret)
+	h1.addConfigMap(cm)
+
+	h2 := newContentHasher()
+	h2.addConfigMap(cm)
+	h2.addSecret(secret)
+
+	if h1.sum() != h2.sum() {
+		t.Errorf("sum() depends on the order in which objects are added")
+	}
+
+	changed := secret.DeepCopy()
+	changed.Data[userTOMLFile] = []byte("port = 7000")
+
+	h3 := newContentHasher()
+	h3.addSecret(changed)
+	h3.addConfigMap(cm)
+
+	if h1.sum() == h3.sum() {
+		t.Errorf("sum() did not change when the contents of a Secret changed")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"sort"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Render returns the objects the controller would create for habitats, without
+// a cluster. The Secrets and ConfigMaps the Habitats reference are read from
+// secrets and configMaps. The PersistentVolumeClaims created by the
+// StatefulSet controller are not included. The StatefulSets get the defaults,
+// and the features enabled by featureGates, of new Habitats.
+func Render(habitats []*habv1beta1.Habitat, secrets []*apiv1.Secret, configMaps []*apiv1.ConfigMap, defaults HabitatDefaults, featureGates map[string]bool) ([]runtime.Object, error) {
+	if err := ValidateFeatureGates(featureGates); err != nil {
+		return nil, err
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		serviceGroupIndex:    serviceGroupIndexFunc,
+		bindTargetIndex:      bindTargetIndexFunc,
+	})
+	for _, h := range habitats {
+		if err := validateCustomObject(*h); err != nil {
+			return nil, fmt.Errorf("invalid Habitat %s: %v", habitatKey(h), err)
+		}
+
+		if err := indexer.Add(h); err != nil {
+			return nil, err
+		}
+	}
+
+	objects := newStaticObjectGetter(secrets, configMaps)
+	hc := &HabitatController{
+		config: Config{
+			HabitatDefaults: defaults,
+			FeatureGates:    featureGates,
+		},
+		logger:  log.NewNopLogger(),
+		objects: objects,
+	}
+
+	var rendered []runtime.Object
+	namespaces := map[string]*habv1beta1.Habitat{}
+
+	for _, h := range habitats {
+		if _, ok := namespaces[h.Namespace]; !ok {
+			namespaces[h.Namespace] = h
+		}
+
+		if needsRenderedUserConfig(h.Spec.V1beta2.Service) {
+			secret, err := hc.renderUserConfigSecret(h)
+			if err != nil {
+				return nil, fmt.Errorf("Habitat %s: %v", habitatKey(h), err)
+			}
+			secret.TypeMeta = typeMeta(apiv1.SchemeGroupVersion.String(), "Secret")
+
+			// The StatefulSet mounts the rendered Secret.
+			objects.secrets[fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)] = secret
+			rendered = append(rendered, secret)
+		}
+
+		sts, err := hc.newStatefulSet(h)
+		if err != nil {
+			return nil, fmt.Errorf("Habitat %s: %v", habitatKey(h), err)
+		}
+		if err := setLastApplied(sts); err != nil {
+			return nil, err
+		}
+		sts.Namespace = h.Namespace
+		sts.TypeMeta = typeMeta(appsv1.SchemeGroupVersion.String(), "StatefulSet")
+		rendered = append(rendered, sts)
+
+		if h.Spec.V1beta2.NetworkPolicy != nil {
+			consumers, err := sameNamespaceConsumers(indexer, h)
+			if err != nil {
+				return nil, err
+			}
+
+			np := newNetworkPolicy(h, consumers)
+			if err := setLastApplied(np); err != nil {
+				return nil, err
+			}
+			np.TypeMeta = typeMeta(networkingv1.SchemeGroupVersion.String(), "NetworkPolicy")
+			rendered = append(rendered, np)
+		}
+
+		if hc.featureEnabled(FeatureGatePodDisruptionBudgets) && pdbEnabled(h) {
+			pdb := newPodDisruptionBudget(h)
+			pdb.TypeMeta = typeMeta(policyv1beta1.SchemeGroupVersion.String(), "PodDisruptionBudget")
+			rendered = append(rendered, pdb)
+		}
+	}
+
+	// Each namespace has a single peer ConfigMap, owned by the first Habitat
+	// reconciled in it, and a bind graph, owned by all of its Habitats.
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	for _, ns := range names {
+		cm := newConfigMap("", namespaces[ns])
+		cm.TypeMeta = typeMeta(apiv1.SchemeGroupVersion.String(), "ConfigMap")
+		rendered = append(rendered, cm)
+
+		g, err := newBindGraph(indexer, ns)
+		if err != nil {
+			return nil, err
+		}
+
+		habitats, err := g.habitats(indexer)
+		if err != nil {
+			return nil, err
+		}
+
+		graphCM, err := newBindGraphConfigMap(ns, g, habitats)
+		if err != nil {
+			return nil, err
+		}
+		graphCM.TypeMeta = typeMeta(apiv1.SchemeGroupVersion.String(), "ConfigMap")
+		rendered = append(rendered, graphCM)
+	}
+
+	return rendered, nil
+}
+
+func typeMeta(apiVersion, kind string) metav1.TypeMeta {
+	return metav1.TypeMeta{APIVersion: apiVersion, Kind: kind}
+}
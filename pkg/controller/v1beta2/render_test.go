@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRender(t *testing.T) {
+	base := &apiv1.ConfigMap{Data: map[string]string{userTOMLFile: "port = 6379\n"}}
+	base.Name = "base"
+	base.Namespace = "default"
+
+	h := newTestHabitat("default", "foo", "redis", nil)
+	h.Spec.V1beta2.Count = 1
+	h.Spec.V1beta2.Image = "habitat/redis-hab"
+	h.Spec.V1beta2.Service.Topology = habv1beta1.TopologyStandalone
+	h.Spec.V1beta2.Service.ConfigMapRef = &apiv1.LocalObjectReference{Name: "base"}
+	h.Spec.V1beta2.Service.Config = &habv1beta1.ServiceConfig{Values: map[string]string{"port": "6380"}}
+
+	defaults := HabitatDefaults{
+		Resources: apiv1.ResourceRequirements{
+			Requests: apiv1.ResourceList{apiv1.ResourceMemory: resource.MustParse("64Mi")},
+		},
+	}
+
+	objects, err := Render([]*habv1beta1.Habitat{h}, nil, []*apiv1.ConfigMap{base}, defaults, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, obj := range objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	want := []string{"Secret", "StatefulSet", "PodDisruptionBudget", "ConfigMap", "ConfigMap"}
+	if len(kinds) != len(want) {
+		t.Fatalf("Render() kinds = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("Render() kinds = %v, want %v", kinds, want)
+		}
+	}
+
+	sts := objects[1].(*appsv1.StatefulSet)
+	if sts.Namespace != "default" || sts.Annotations[habv1beta1.LastAppliedAnnotation] == "" {
+		t.Errorf("rendered StatefulSet = %+v, want it in namespace default, with its last-applied configuration", sts.ObjectMeta)
+	}
+	if got := sts.Spec.Template.Spec.Containers[0].Resources.Requests.Memory(); got.String() != "64Mi" {
+		t.Errorf("rendered memory request = %s, want the default of 64Mi", got.String())
+	}
+
+	// The PodDisruptionBudget is left out when its feature gate is disabled.
+	objects, err = Render([]*habv1beta1.Habitat{h}, nil, []*apiv1.ConfigMap{base}, defaults, map[string]bool{FeatureGatePodDisruptionBudgets: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects {
+		if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind == "PodDisruptionBudget" {
+			t.Error("Render() with the PodDisruptionBudgets gate disabled rendered a PodDisruptionBudget")
+		}
+	}
+
+	// References which can't be found are reported.
+	h.Spec.V1beta2.Service.Config = nil
+	h.Spec.V1beta2.Service.ConfigMapRef = &apiv1.LocalObjectReference{Name: "missing"}
+	if _, err := Render([]*habv1beta1.Habitat{h}, nil, nil, HabitatDefaults{}, nil); err == nil {
+		t.Error("Render() with a missing ConfigMap returned no error")
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// shardLeaseLabel marks the ConfigMaps holding the leases of the operator
+	// replicas sharing the Habitats. Leases of the coordination.k8s.io API group
+	// aren't available in all the supported versions of Kubernetes.
+	shardLeaseLabel = "operator.habitat.sh/shard-lease"
+	// shardLeasePrefix is the prefix of the names of the lease ConfigMaps.
+	shardLeasePrefix = "habitat-operator-shard-"
+
+	shardHolderKey        = "holderIdentity"
+	shardRenewTimeKey     = "renewTime"
+	shardLeaseDurationKey = "leaseDurationSeconds"
+
+	// shardVirtualNodes is the number of points of each replica on the hash
+	// ring, which spreads the Habitats evenly across them.
+	shardVirtualNodes = 100
+)
+
+// ShardingConfig makes the controller reconcile only its share of the
+// Habitats, so that they're spread across several replicas of the operator.
+type ShardingConfig struct {
+	// Namespace is the namespace of the lease ConfigMaps of the replicas.
+	Namespace string
+	// Identity uniquely identifies the replica, e.g. the name of its Pod.
+	Identity string
+	// LeaseDuration is how long a replica is considered a member after last
+	// renewing its lease. Leases are renewed every third of it. It must be at
+	// least a second, as leases store it in seconds.
+	LeaseDuration time.Duration
+}
+
+// hashRing assigns keys to members by consistent hashing, so that only the
+// keys of the members joining or leaving are reassigned.
+type hashRing struct {
+	points  []uint32
+	members map[uint32]string
+}
+
+func hashKey(s string) uint32 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+func newHashRing(members []string) *hashRing {
+	r := &hashRing{members: map[uint32]string{}}
+
+	for _, m := range members {
+		for i := 0; i < shardVirtualNodes; i++ {
+			p := hashKey(m + "#" + strconv.Itoa(i))
+			if _, ok := r.members[p]; ok {
+				continue
+			}
+
+			r.members[p] = m
+			r.points = append(r.points, p)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+// member returns the member key is assigned to, or an empty string if the ring
+// has no members.
+func (r *hashRing) member(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.members[r.points[i]]
+}
+
+// observedLease is the last renewal of a lease seen by the replica. Leases
+// expire after their duration has passed on the replica's clock since their
+// renewal was observed, so that the clocks of the replicas don't need to
+// agree.
+type observedLease struct {
+	renewTime string
+	duration  time.Duration
+	observed  time.Time
+}
+
+// shardMembership keeps the lease of the replica and tracks the leases of the
+// others.
+type shardMembership struct {
+	config    ShardingConfig
+	clientset kubernetes.Interface
+	logger    log.Logger
+
+	// onChange is called whenever the members change.
+	onChange func()
+
+	mu      sync.RWMutex
+	leases  map[string]observedLease
+	members []string
+	ring    *hashRing
+	// renewed is when the replica last renewed its lease, and held is when it
+	// started holding it without interruption.
+	renewed time.Time
+	held    time.Time
+	active  bool
+
+	now func() time.Time
+}
+
+func newShardMembership(config ShardingConfig, clientset kubernetes.Interface, logger log.Logger, onChange func()) *shardMembership {
+	return &shardMembership{
+		config:    config,
+		clientset: clientset,
+		logger:    logger,
+		onChange:  onChange,
+		leases:    map[string]observedLease{},
+		ring:      newHashRing(nil),
+		now:       time.Now,
+	}
+}
+
+// owns returns whether the Habitat with the key namespace/name is assigned to
+// the replica.
+func (m *shardMembership) owns(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.activeLocked() && m.ring.member(key) == m.config.Identity
+}
+
+// activeLocked returns whether the replica may reconcile Habitats. After
+// joining, a replica waits for a whole lease duration, by which time the other
+// replicas have seen its lease and stopped reconciling the Habitats it takes
+// over. It stops as soon as its lease may have expired for the others, which
+// count the duration from a later time, when they observed the renewal.
+func (m *shardMembership) activeLocked() bool {
+	now := m.now()
+
+	return !m.held.IsZero() &&
+		now.Sub(m.renewed) <= m.config.LeaseDuration &&
+		now.Sub(m.held) >= m.config.LeaseDuration
+}
+
+func (m *shardMembership) leaseName() string {
+	return shardLeasePrefix + m.config.Identity
+}
+
+// renew creates or renews the lease of the replica.
+func (m *shardMembership) renew() error {
+	// The time is taken before the request, as the other replicas observe the
+	// renewal after it.
+	now := m.now()
+	if err := m.writeLease(now); err != nil {
+		return err
+	}
+
+	m.recordRenewal(now)
+
+	return nil
+}
+
+// recordRenewal records that the lease was renewed at now. The replica holds
+// it without interruption as long as it's renewed before expiring.
+func (m *shardMembership) recordRenewal(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held.IsZero() || now.Sub(m.renewed) > m.config.LeaseDuration {
+		m.held = now
+	}
+	m.renewed = now
+}
+
+func (m *shardMembership) writeLease(now time.Time) error {
+	configMaps := m.clientset.CoreV1().ConfigMaps(m.config.Namespace)
+
+	data := map[string]string{
+		shardHolderKey:        m.config.Identity,
+		shardRenewTimeKey:     now.UTC().Format(time.RFC3339Nano),
+		shardLeaseDurationKey: strconv.Itoa(int(m.config.LeaseDuration.Seconds())),
+	}
+
+	cm, err := configMaps.Get(m.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(&apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.leaseName(),
+				Namespace: m.config.Namespace,
+				Labels: map[string]string{
+					shardLeaseLabel: "true",
+				},
+			},
+			Data: data,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data = data
+	_, err = configMaps.Update(cm)
+
+	return err
+}
+
+// refresh updates the members from the leases, and calls onChange if they
+// changed.
+func (m *shardMembership) refresh() error {
+	ls := labels.SelectorFromSet(labels.Set{shardLeaseLabel: "true"})
+	list, err := m.clientset.CoreV1().ConfigMaps(m.config.Namespace).List(metav1.ListOptions{LabelSelector: ls.String()})
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+
+	m.mu.Lock()
+
+	leases := map[string]observedLease{}
+	var expired []apiv1.ConfigMap
+	for _, cm := range list.Items {
+		holder := cm.Data[shardHolderKey]
+		if holder == "" {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(cm.Data[shardLeaseDurationKey])
+		if err != nil {
+			level.Debug(m.logger).Log("msg", "ignoring lease with invalid duration", "name", cm.Name)
+			continue
+		}
+
+		l := observedLease{
+			renewTime: cm.Data[shardRenewTimeKey],
+			duration:  time.Duration(seconds) * time.Second,
+			observed:  now,
+		}
+		if old, ok := m.leases[holder]; ok && old.renewTime == l.renewTime {
+			l.observed = old.observed
+		}
+		leases[holder] = l
+
+		// The leases of replicas which are gone are cleaned up by the others.
+		if holder != m.config.Identity && now.Sub(l.observed) > l.duration {
+			expired = append(expired, cm)
+		}
+	}
+	m.leases = leases
+
+	// The replica itself drops out too if it fails to renew its lease, so that
+	// none of its Habitats is reconciled by two replicas.
+	members := []string{}
+	for holder, l := range leases {
+		if now.Sub(l.observed) <= l.duration {
+			members = append(members, holder)
+		}
+	}
+	sort.Strings(members)
+
+	changed := !reflect.DeepEqual(members, m.members)
+	if changed {
+		m.members = members
+		m.ring = newHashRing(members)
+	}
+
+	m.mu.Unlock()
+
+	for _, cm := range expired {
+		err := m.clientset.CoreV1().ConfigMaps(m.config.Namespace).Delete(cm.Name, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &cm.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			level.Error(m.logger).Log("msg", "failed to delete expired shard lease", "name", cm.Name, "err", err)
+			continue
+		}
+
+		level.Info(m.logger).Log("msg", "deleted expired shard lease", "name", cm.Name)
+	}
+
+	if changed {
+		level.Info(m.logger).Log("msg", "operator replicas changed, rebalancing Habitats", "members", strings.Join(members, ","))
+		m.onChange()
+	}
+
+	return nil
+}
+
+// checkActive calls onChange when the replica starts reconciling Habitats,
+// which it skipped while waiting to take them over.
+func (m *shardMembership) checkActive() {
+	m.mu.Lock()
+	active := m.activeLocked()
+	activated := active && !m.active
+	m.active = active
+	m.mu.Unlock()
+
+	if activated {
+		level.Info(m.logger).Log("msg", "shard lease held for a lease duration, reconciling Habitats")
+		m.onChange()
+	}
+}
+
+// start joins the replicas and keeps the lease of the replica until ctx is
+// done, when the lease is released.
+func (m *shardMembership) start(ctx context.Context) error {
+	if err := m.renew(); err != nil {
+		return err
+	}
+	if err := m.refresh(); err != nil {
+		return err
+	}
+
+	go func() {
+		wait.Until(func() {
+			if err := m.renew(); err != nil {
+				level.Error(m.logger).Log("msg", "failed to renew shard lease", "err", err)
+			}
+			if err := m.refresh(); err != nil {
+				level.Error(m.logger).Log("msg", "failed to read shard leases", "err", err)
+			}
+			m.checkActive()
+		}, m.config.LeaseDuration/3, ctx.Done())
+
+		// Let the other replicas take over right away.
+		err := m.clientset.CoreV1().ConfigMaps(m.config.Namespace).Delete(m.leaseName(), &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			level.Error(m.logger).Log("msg", "failed to release shard lease", "err", err)
+		}
+	}()
+
+	return nil
+}
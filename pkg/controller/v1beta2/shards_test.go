@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestHashRing(t *testing.T) {
+	if m := newHashRing(nil).member("default/foo"); m != "" {
+		t.Errorf("empty ring assigned key to %q", m)
+	}
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("ns-%d/habitat-%d", i%7, i)
+	}
+
+	three := newHashRing([]string{"a", "b", "c"})
+	two := newHashRing([]string{"a", "b"})
+
+	counts := map[string]int{}
+	for _, k := range keys {
+		m := three.member(k)
+		counts[m]++
+
+		// Only the keys of the member which left are reassigned.
+		if m != "c" && two.member(k) != m {
+			t.Errorf("key %q moved from %q to %q", k, m, two.member(k))
+		}
+	}
+
+	for _, m := range []string{"a", "b", "c"} {
+		if counts[m] < len(keys)/6 {
+			t.Errorf("member %q was assigned %d of %d keys", m, counts[m], len(keys))
+		}
+	}
+}
+
+func TestShardMembershipFencing(t *testing.T) {
+	now := time.Unix(0, 0)
+	activations := 0
+
+	m := newShardMembership(ShardingConfig{Identity: "a", LeaseDuration: 15 * time.Second}, nil, log.NewNopLogger(), func() { activations++ })
+	m.now = func() time.Time { return now }
+	m.ring = newHashRing([]string{"a"})
+
+	// A replica which just joined waits for the others to hand its Habitats
+	// over.
+	m.recordRenewal(now)
+	m.checkActive()
+	if m.owns("default/foo") {
+		t.Error("owns() = true right after joining, want false")
+	}
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(5 * time.Second)
+		m.recordRenewal(now)
+		m.checkActive()
+	}
+	if !m.owns("default/foo") {
+		t.Error("owns() = false after holding the lease for a lease duration, want true")
+	}
+	if activations != 1 {
+		t.Errorf("onChange called %d times on activation, want 1", activations)
+	}
+
+	// It stops as soon as its lease may have expired, even if it couldn't
+	// read the leases.
+	now = now.Add(16 * time.Second)
+	if m.owns("default/foo") {
+		t.Error("owns() = true after failing to renew the lease, want false")
+	}
+
+	// And waits again once it renews it.
+	m.recordRenewal(now)
+	if m.owns("default/foo") {
+		t.Error("owns() = true right after renewing an expired lease, want false")
+	}
+}
@@ -15,23 +15,61 @@
 package v1beta2
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"reflect"
 
 	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
 
 	"github.com/go-kit/kit/log/level"
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 )
 
-const persistentVolumeName = "persistent"
+const (
+	persistentVolumeName   = "persistent"
+	filesSourcesVolumeName = "files-sources"
+	filesSourcesDir        = "/mnt/files"
+
+	// copyFilesScript copies the files directory from its sources to the
+	// directory passed as the first argument.
+	// Kubernetes keeps the contents of Secret, ConfigMap and Projected volumes
+	// in hidden directories, whose names start with `..`, and exposes each key
+	// as a symlink pointing into them. Those directories are skipped, and the
+	// symlinks are dereferenced, so that nested paths and dotfiles are copied
+	// along with their modes.
+	copyFilesScript = `cd ` + filesSourcesDir + ` && find . -mindepth 1 -maxdepth 1 ! -name '..*' -exec cp -RLp {} "$1" \;`
+
+	// templateHashAnnotation is set on the StatefulSet, and contains a hash
+	// of the Pod template desired by the operator. Unlike the template read
+	// back from the API server, it doesn't contain defaulted fields, so it
+	// only changes when the Pods need to be updated.
+	templateHashAnnotation = "operator.habitat.sh/template-hash"
+)
+
+// filesMode returns the FilesMode of the service, applying the default.
+func filesMode(s habv1beta1.ServiceV1beta2) habv1beta1.FilesMode {
+	if s.FilesMode == nil {
+		return habv1beta1.FilesModeCopy
+	}
+
+	return *s.FilesMode
+}
 
 func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.StatefulSet, error) {
 	hs := h.Spec.V1beta2
 
+	defaults, err := hc.habitatDefaults(h)
+	if err != nil {
+		return nil, err
+	}
+
 	// This value needs to be passed as a *int32, so we convert it, assign it to a
 	// variable and afterwards pass a pointer to it.
 	count := int32(hs.Count)
@@ -78,6 +116,8 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 			"--bind", bindArg)
 	}
 
+	habArgs = append(habArgs, supervisorArgs(hs)...)
+
 	base := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: h.Name,
@@ -118,6 +158,7 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 							Name:  "habitat-service",
 							Image: hs.Image,
 							Args:  habArgs,
+							Ports: containerPorts(hs),
 							VolumeMounts: []apiv1.VolumeMount{
 								{
 									Name:      "config",
@@ -125,9 +166,11 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 									ReadOnly:  true,
 								},
 							},
-							Env: hs.Env,
+							Env:       supervisorEnv(hs),
+							Resources: defaults.Resources,
 						},
 					},
+					TerminationGracePeriodSeconds: terminationGracePeriod(hs.Service),
 					// Define the volume for the ConfigMap.
 					Volumes: []apiv1.Volume{
 						{
@@ -162,30 +205,66 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 	spec := &base.Spec
 	tSpec := &spec.Template.Spec
 
-	// If we have a secret name present we should mount that secret.
-	if hs.Service.ConfigSecretName != nil {
+	// Keep track of the contents of all the mounted Secrets and ConfigMaps.
+	hasher := newContentHasher()
+
+	userTOMLItems := []apiv1.KeyToPath{
+		{
+			Key:  userTOMLFile,
+			Path: userTOMLFile,
+		},
+	}
+
+	// Find out where the config comes from. Config specified inline or coming
+	// from multiple sources is rendered into a Secret of its own.
+	configSecretName := hs.Service.ConfigSecretName
+	if needsRenderedUserConfig(hs.Service) {
+		name := userConfigSecretName(h)
+		configSecretName = &name
+	}
+
+	var configVolumeSource *apiv1.VolumeSource
+	switch {
+	case configSecretName != nil:
 		// Let's make sure our secret is there before mounting it.
-		secret, err := hc.config.KubernetesClientset.CoreV1().Secrets(h.Namespace).Get(*hs.Service.ConfigSecretName, metav1.GetOptions{})
+		secret, err := hc.objects.getSecret(h.Namespace, *configSecretName)
 		if err != nil {
 			return nil, err
 		}
+		hasher.addSecret(secret)
 
-		secretVolume := &apiv1.Volume{
-			Name: userConfigFilename,
-			VolumeSource: apiv1.VolumeSource{
-				Secret: &apiv1.SecretVolumeSource{
-					SecretName: secret.Name,
-					Items: []apiv1.KeyToPath{
-						{
-							Key:  userTOMLFile,
-							Path: userTOMLFile,
-						},
-					},
+		configVolumeSource = &apiv1.VolumeSource{
+			Secret: &apiv1.SecretVolumeSource{
+				SecretName: secret.Name,
+				Items:      userTOMLItems,
+			},
+		}
+	case hs.Service.ConfigMapRef != nil:
+		// Let's make sure our ConfigMap is there before mounting it.
+		cm, err := hc.objects.getConfigMap(h.Namespace, hs.Service.ConfigMapRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		hasher.addConfigMap(cm)
+
+		configVolumeSource = &apiv1.VolumeSource{
+			ConfigMap: &apiv1.ConfigMapVolumeSource{
+				LocalObjectReference: apiv1.LocalObjectReference{
+					Name: cm.Name,
 				},
+				Items: userTOMLItems,
 			},
 		}
+	}
 
-		secretVolumeMount := &apiv1.VolumeMount{
+	// If we have a config source we should mount it.
+	if configVolumeSource != nil {
+		configVolume := &apiv1.Volume{
+			Name:         userConfigFilename,
+			VolumeSource: *configVolumeSource,
+		}
+
+		configVolumeMount := &apiv1.VolumeMount{
 			Name: userConfigFilename,
 			// The Habitat supervisor creates a directory for each service under /hab/svc/<servicename>.
 			// We need to place the user.toml file in there in order for it to be detected.
@@ -193,121 +272,166 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 			ReadOnly:  false,
 		}
 
-		tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, *secretVolumeMount)
-		tSpec.Volumes = append(tSpec.Volumes, *secretVolume)
+		tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, *configVolumeMount)
+		tSpec.Volumes = append(tSpec.Volumes, *configVolume)
 	}
 
-	// If we have a file volume name present we should mount that secret.
+	// Collect the sources of the files directory.
+	var filesSources []apiv1.VolumeProjection
+
 	if hs.Service.FilesSecretName != nil {
 		// Let's make sure our secret is there before mounting it.
-		files, err := hc.config.KubernetesClientset.CoreV1().Secrets(h.Namespace).Get(*hs.Service.FilesSecretName, metav1.GetOptions{})
+		files, err := hc.objects.getSecret(h.Namespace, *hs.Service.FilesSecretName)
 		if err != nil {
 			return nil, err
 		}
+		hasher.addSecret(files)
 
-		// In order to mount the volume such that hab can change the permissions, we need to
-		//   #1. Create A Secret Volume based on the supplied secret
-		//   #2. Create an EmptyDir Volume to hold /hab/svc/NAME/files
-		//   #3. Add an initContainer to copy the files from the Secret Volume to the EmptyDir Volume
-		//   #4. Mount only the EmptyDir Volume into the habitat service container
-
-		// #1
-		filesSecretVolume := &apiv1.Volume{
-			Name: "files-secrets",
-			VolumeSource: apiv1.VolumeSource{
-				Secret: &apiv1.SecretVolumeSource{
-					SecretName: files.Name,
+		filesSources = append(filesSources, apiv1.VolumeProjection{
+			Secret: &apiv1.SecretProjection{
+				LocalObjectReference: apiv1.LocalObjectReference{
+					Name: files.Name,
 				},
 			},
-		}
-		tSpec.Volumes = append(tSpec.Volumes, *filesSecretVolume)
+		})
+	}
 
-		filesSecretVolumeMount := &apiv1.VolumeMount{
-			Name:      "files-secrets",
-			MountPath: "/mnt/files",
+	if hs.Service.FilesConfigMapRef != nil {
+		// Let's make sure our ConfigMap is there before mounting it.
+		files, err := hc.objects.getConfigMap(h.Namespace, hs.Service.FilesConfigMapRef.Name)
+		if err != nil {
+			return nil, err
 		}
+		hasher.addConfigMap(files)
 
-		// #2
-		filesVolume := &apiv1.Volume{
-			Name: filesDirectoryName,
-			VolumeSource: apiv1.VolumeSource{
-				EmptyDir: &apiv1.EmptyDirVolumeSource{},
+		filesSources = append(filesSources, apiv1.VolumeProjection{
+			ConfigMap: &apiv1.ConfigMapProjection{
+				LocalObjectReference: apiv1.LocalObjectReference{
+					Name: files.Name,
+				},
 			},
-		}
-		tSpec.Volumes = append(tSpec.Volumes, *filesVolume)
-
-		filesVolumeMount := &apiv1.VolumeMount{
-			Name: filesDirectoryName,
-			// The Habitat supervisor creates a directory for each service under /hab/svc/<servicename>.
-			// We need to place the files directory there.
-			MountPath: fmt.Sprintf("/hab/svc/%s/files", hs.Service.Name),
-			ReadOnly:  false,
-		}
-
-		// #3
-		command := fmt.Sprintf("cp /mnt/files/* /hab/svc/%s/files", hs.Service.Name)
-		initContainer := &apiv1.Container{
-			Name:         "copy-files",
-			Image:        "busybox",
-			Command:      []string{"sh", "-c", command},
-			VolumeMounts: []apiv1.VolumeMount{},
-		}
-		initContainer.VolumeMounts = append(initContainer.VolumeMounts, *filesSecretVolumeMount)
-		initContainer.VolumeMounts = append(initContainer.VolumeMounts, *filesVolumeMount)
-
-		// #4
-		tSpec.InitContainers = append(tSpec.InitContainers, *initContainer)
-		tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, *filesVolumeMount)
-
+		})
 	}
 
-	// Mount Persistent Volume, if requested.
-	if ps := hs.PersistentStorage; ps != nil {
-		vm := &apiv1.VolumeMount{
-			Name:      persistentVolumeName,
-			MountPath: ps.MountPath,
+	// The additional sources are not required to exist, as they can be marked
+	// as optional.
+	for _, fs := range hs.Service.FilesSources {
+		switch {
+		case fs.Secret != nil:
+			s, err := hc.objects.getSecret(h.Namespace, fs.Secret.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			} else if err == nil {
+				hasher.addSecret(s)
+			}
+		case fs.ConfigMap != nil:
+			cm, err := hc.objects.getConfigMap(h.Namespace, fs.ConfigMap.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			} else if err == nil {
+				hasher.addConfigMap(cm)
+			}
 		}
+	}
 
-		tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, *vm)
+	filesSources = append(filesSources, hs.Service.FilesSources...)
 
-		q, err := resource.ParseQuantity(ps.Size)
-		if err != nil {
-			return nil, fmt.Errorf("Could not parse PersistentStorage.Size: %v", err)
+	// If we have any files sources we should mount them.
+	if len(filesSources) > 0 {
+		filesSourcesVolume := &apiv1.Volume{
+			Name: filesSourcesVolumeName,
+			VolumeSource: apiv1.VolumeSource{
+				Projected: &apiv1.ProjectedVolumeSource{
+					Sources: filesSources,
+				},
+			},
 		}
-
-		spec.VolumeClaimTemplates = []apiv1.PersistentVolumeClaim{
-			apiv1.PersistentVolumeClaim{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      persistentVolumeName,
-					Namespace: h.Namespace,
-					Labels: map[string]string{
-						habv1beta1.HabitatLabel:     "true",
-						habv1beta1.HabitatNameLabel: h.Name,
-					},
+		tSpec.Volumes = append(tSpec.Volumes, *filesSourcesVolume)
+
+		// The Habitat supervisor creates a directory for each service under /hab/svc/<servicename>.
+		// We need to place the files directory there.
+		filesDir := fmt.Sprintf("/hab/svc/%s/files", hs.Service.Name)
+
+		if filesMode(hs.Service) == habv1beta1.FilesModeMount {
+			// Mount the sources directly. The supervisor won't be able to
+			// change the files, but no init container is required.
+			tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, apiv1.VolumeMount{
+				Name:      filesSourcesVolumeName,
+				MountPath: filesDir,
+				ReadOnly:  true,
+			})
+		} else {
+			// In order to mount the volume such that hab can change the permissions, we need to
+			//   #1. Mount the Projected Volume merging the supplied sources in the init container
+			//   #2. Create an EmptyDir Volume to hold /hab/svc/NAME/files
+			//   #3. Add an initContainer to copy the files from the Projected Volume to the EmptyDir Volume
+			//   #4. Mount only the EmptyDir Volume into the habitat service container
+
+			// #1
+			filesSourcesVolumeMount := &apiv1.VolumeMount{
+				Name:      filesSourcesVolumeName,
+				MountPath: filesSourcesDir,
+				ReadOnly:  true,
+			}
+
+			// #2
+			filesVolume := &apiv1.Volume{
+				Name: filesDirectoryName,
+				VolumeSource: apiv1.VolumeSource{
+					EmptyDir: &apiv1.EmptyDirVolumeSource{},
 				},
-				Spec: apiv1.PersistentVolumeClaimSpec{
-					AccessModes: []apiv1.PersistentVolumeAccessMode{
-						apiv1.ReadWriteOnce,
-					},
-					StorageClassName: &ps.StorageClassName,
-					Resources: apiv1.ResourceRequirements{
-						Requests: apiv1.ResourceList{
-							apiv1.ResourceStorage: q,
-						},
-					},
+			}
+			tSpec.Volumes = append(tSpec.Volumes, *filesVolume)
+
+			filesVolumeMount := &apiv1.VolumeMount{
+				Name:      filesDirectoryName,
+				MountPath: filesDir,
+				ReadOnly:  false,
+			}
+
+			// #3
+			// The image of the Habitat service is used by default, so that no
+			// image other than the one specified by the user has to be pulled.
+			initImage := hs.Image
+			if defaults.FilesInitImage != "" {
+				initImage = defaults.FilesInitImage
+			}
+			if hs.Service.FilesInitImage != nil {
+				initImage = *hs.Service.FilesInitImage
+			}
+
+			initContainer := &apiv1.Container{
+				Name:  "copy-files",
+				Image: initImage,
+				// The script receives the destination directory as its first
+				// positional parameter, so that it doesn't need to be quoted.
+				Command: []string{"sh", "-c", copyFilesScript, "copy-files", filesDir},
+				VolumeMounts: []apiv1.VolumeMount{
+					*filesSourcesVolumeMount,
+					*filesVolumeMount,
 				},
-			},
+			}
+
+			// #4
+			tSpec.InitContainers = append(tSpec.InitContainers, *initContainer)
+			tSpec.Containers[0].VolumeMounts = append(tSpec.Containers[0].VolumeMounts, *filesVolumeMount)
 		}
 	}
 
+	// Mount Persistent Volumes, if requested.
+	if err := addPersistentVolumes(h, spec); err != nil {
+		return nil, err
+	}
+
 	// Handle ring key, if one is specified.
 	if ringSecretName := hs.Service.RingSecretName; ringSecretName != nil {
 		ringSecretName := *ringSecretName
-		s, err := hc.config.KubernetesClientset.CoreV1().Secrets(apiv1.NamespaceDefault).Get(ringSecretName, metav1.GetOptions{})
+		s, err := hc.objects.getSecret(h.Namespace, ringSecretName)
 		if err != nil {
 			level.Error(hc.logger).Log("msg", "Could not find Secret containing ring key")
 			return nil, err
 		}
+		hasher.addSecret(s)
 
 		// The filename under which the ring key is saved.
 		ringKeyFile := fmt.Sprintf("%s.%s", ringSecretName, ringKeyFileExt)
@@ -347,11 +471,80 @@ func (hc *HabitatController) newStatefulSet(h *habv1beta1.Habitat) (*appsv1.Stat
 		tSpec.Containers[0].Args = append(tSpec.Containers[0].Args, "--ring", ringName)
 	}
 
+	// Changes to the contents of the mounted objects change the template,
+	// which in turn causes the Pods to be updated.
+	if sum := hasher.sum(); sum != "" {
+		spec.Template.Annotations = map[string]string{
+			configHashAnnotation: sum,
+		}
+	}
+
+	// Likewise, setting the restart annotation on the Habitat restarts its
+	// Pods.
+	if restartedAt, ok := h.Annotations[habv1beta1.RestartedAtAnnotation]; ok {
+		if spec.Template.Annotations == nil {
+			spec.Template.Annotations = map[string]string{}
+		}
+		spec.Template.Annotations[habv1beta1.RestartedAtAnnotation] = restartedAt
+	}
+
+	if err := recordHabitatDefaults(base, defaults); err != nil {
+		return nil, err
+	}
+
+	hash, err := templateHash(spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	if base.Annotations == nil {
+		base.Annotations = map[string]string{}
+	}
+	base.Annotations[templateHashAnnotation] = hash
+
 	return base, nil
 }
 
+// templateHash returns a hash of the Pod template t.
+func templateHash(t apiv1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// templateChanged returns true if the Pods of oldSts have to be updated to
+// match the Pod template of updatedSts.
+func templateChanged(oldSts, updatedSts *appsv1.StatefulSet) bool {
+	oldHash, ok := oldSts.Annotations[templateHashAnnotation]
+	// StatefulSets created by older versions of the operator have no hash.
+	if !ok {
+		return !reflect.DeepEqual(oldSts.Spec.Template, updatedSts.Spec.Template)
+	}
+
+	return oldHash != updatedSts.Annotations[templateHashAnnotation]
+}
+
+// applyStatefulSet patches oldSts with the changes to the StatefulSet newSts,
+// as desired by the operator, and returns the result.
+func (hc *HabitatController) applyStatefulSet(oldSts, newSts *appsv1.StatefulSet) (*appsv1.StatefulSet, error) {
+	patch, err := applyPatch(newSts, oldSts, appsv1.StatefulSet{})
+	if err != nil {
+		return nil, err
+	}
+
+	if patch == nil {
+		return oldSts, nil
+	}
+
+	return hc.config.KubernetesClientset.AppsV1().StatefulSets(oldSts.Namespace).Patch(oldSts.Name, types.StrategicMergePatchType, patch)
+}
+
 func (hc *HabitatController) cacheStatefulSets() {
-	hc.stsInformer = hc.config.KubeInformerFactory.Apps().V1().StatefulSets().Informer()
+	hc.stsInformer = hc.kubeInformer(func(f kubeinformers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().StatefulSets().Informer()
+	})
 
 	hc.stsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    hc.handleStsAdd,
@@ -403,6 +596,11 @@ func (hc *HabitatController) handleStsUpdate(oldObj, newObj interface{}) {
 	}
 
 	hc.enqueue(h)
+
+	// Consumers might be waiting for this Habitat's Pods to be ready.
+	if oldSTS.Status.ReadyReplicas != newSTS.Status.ReadyReplicas {
+		hc.enqueueConsumers(h)
+	}
 }
 
 func (hc *HabitatController) handleStsDelete(obj interface{}) {
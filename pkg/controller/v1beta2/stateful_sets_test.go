@@ -0,0 +1,184 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func newTestHashedStatefulSet(t *testing.T, image string) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{}
+	sts.Name = "foo"
+	sts.Spec.Template.Spec.Containers = []apiv1.Container{
+		{Name: "habitat-service", Image: image},
+	}
+
+	hash, err := templateHash(sts.Spec.Template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sts.Annotations = map[string]string{templateHashAnnotation: hash}
+
+	return sts
+}
+
+func TestTemplateChanged(t *testing.T) {
+	oldSts := newTestHashedStatefulSet(t, "foo/bar:1")
+	// Fields defaulted by the API server don't change the hash.
+	oldSts.Spec.Template.Spec.RestartPolicy = apiv1.RestartPolicyAlways
+
+	if templateChanged(oldSts, newTestHashedStatefulSet(t, "foo/bar:1")) {
+		t.Error("templateChanged() = true for the same desired template, want false")
+	}
+	if !templateChanged(oldSts, newTestHashedStatefulSet(t, "foo/bar:2")) {
+		t.Error("templateChanged() = false for a new image, want true")
+	}
+
+	// Without a hash, the templates themselves are compared.
+	delete(oldSts.Annotations, templateHashAnnotation)
+	updatedSts := oldSts.DeepCopy()
+	updatedSts.Annotations[templateHashAnnotation] = "hash"
+	if templateChanged(oldSts, updatedSts) {
+		t.Error("templateChanged() = true for an unchanged template without hash, want false")
+	}
+	updatedSts.Spec.Template.Spec.Containers[0].Image = "foo/bar:2"
+	if !templateChanged(oldSts, updatedSts) {
+		t.Error("templateChanged() = false for a changed template without hash, want true")
+	}
+}
+
+func TestNewStatefulSetRingSecretNamespace(t *testing.T) {
+	ring := &apiv1.Secret{Data: map[string][]byte{ringSecretKey: []byte("key")}}
+	ring.Name = "ring-20180101000000"
+	ring.Namespace = "team-a"
+
+	hc := &HabitatController{
+		logger:  log.NewNopLogger(),
+		objects: newStaticObjectGetter([]*apiv1.Secret{ring}, nil),
+	}
+
+	h := newTestHabitat("team-a", "foo", "redis", nil)
+	h.Spec.V1beta2.Count = 1
+	h.Spec.V1beta2.Image = "habitat/redis-hab"
+	h.Spec.V1beta2.Service.RingSecretName = strToPtr(ring.Name)
+
+	// The ring Secret is mounted from the namespace of the Habitat, so it's
+	// read from there too.
+	sts, err := hc.newStatefulSet(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sts.Spec.Template.Annotations[configHashAnnotation] == "" {
+		t.Error("Pod template has no config hash, want one covering the ring Secret")
+	}
+}
+
+func TestNewStatefulSetFilesMode(t *testing.T) {
+	mount := habv1beta1.FilesModeMount
+
+	tests := []struct {
+		name      string
+		mode      *habv1beta1.FilesMode
+		initImage *string
+		defaults  HabitatDefaults
+		// wantInitImage is empty when no init container is expected.
+		wantInitImage string
+		wantVolume    string
+		wantReadOnly  bool
+	}{
+		{
+			name:          "copy with the image of the service",
+			wantInitImage: "habitat/redis-hab",
+			wantVolume:    filesDirectoryName,
+		},
+		{
+			name:          "copy with the default init image",
+			defaults:      HabitatDefaults{FilesInitImage: "busybox"},
+			wantInitImage: "busybox",
+			wantVolume:    filesDirectoryName,
+		},
+		{
+			name:          "copy with the init image of the spec",
+			initImage:     strToPtr("alpine"),
+			defaults:      HabitatDefaults{FilesInitImage: "busybox"},
+			wantInitImage: "alpine",
+			wantVolume:    filesDirectoryName,
+		},
+		{
+			name:         "mount",
+			mode:         &mount,
+			wantVolume:   filesSourcesVolumeName,
+			wantReadOnly: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := &HabitatController{
+				logger:  log.NewNopLogger(),
+				objects: newStaticObjectGetter(nil, nil),
+			}
+			hc.SetHabitatDefaults(tt.defaults)
+
+			h := newTestDefaultsHabitat("foo")
+			h.Spec.V1beta2.Service.FilesMode = tt.mode
+			h.Spec.V1beta2.Service.FilesInitImage = tt.initImage
+
+			sts, err := hc.newStatefulSet(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tSpec := sts.Spec.Template.Spec
+
+			switch {
+			case tt.wantInitImage == "" && len(tSpec.InitContainers) > 0:
+				t.Errorf("init containers = %v, want none", tSpec.InitContainers)
+			case tt.wantInitImage != "" && len(tSpec.InitContainers) != 1:
+				t.Errorf("%d init containers, want 1", len(tSpec.InitContainers))
+			case tt.wantInitImage != "" && tSpec.InitContainers[0].Image != tt.wantInitImage:
+				t.Errorf("init container image = %q, want %q", tSpec.InitContainers[0].Image, tt.wantInitImage)
+			}
+
+			var filesMount *apiv1.VolumeMount
+			for i, m := range tSpec.Containers[0].VolumeMounts {
+				if m.MountPath == "/hab/svc/redis/files" {
+					filesMount = &tSpec.Containers[0].VolumeMounts[i]
+				}
+			}
+			if filesMount == nil {
+				t.Fatal("files directory not mounted in the service container")
+			}
+			if filesMount.Name != tt.wantVolume || filesMount.ReadOnly != tt.wantReadOnly {
+				t.Errorf("files directory mounted from %q, read-only %v, want %q, read-only %v", filesMount.Name, filesMount.ReadOnly, tt.wantVolume, tt.wantReadOnly)
+			}
+
+			hasVolume := map[string]bool{}
+			for _, v := range tSpec.Volumes {
+				hasVolume[v.Name] = true
+			}
+			if !hasVolume[filesSourcesVolumeName] {
+				t.Errorf("no %s volume", filesSourcesVolumeName)
+			}
+			if want := tt.wantVolume == filesDirectoryName; hasVolume[filesDirectoryName] != want {
+				t.Errorf("%s volume present = %v, want %v", filesDirectoryName, hasVolume[filesDirectoryName], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setCondition adds the condition c to status, replacing any existing
+// condition of the same type. The transition time is only updated when the
+// condition's status changes.
+func setCondition(status *habv1beta1.HabitatStatus, c habv1beta1.HabitatCondition) {
+	for i := range status.Conditions {
+		existing := &status.Conditions[i]
+		if existing.Type != c.Type {
+			continue
+		}
+
+		if existing.Status == c.Status {
+			c.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			c.LastTransitionTime = metav1.Now()
+		}
+		*existing = c
+
+		return
+	}
+
+	c.LastTransitionTime = metav1.Now()
+	status.Conditions = append(status.Conditions, c)
+}
+
+// removeCondition removes the condition of type t from status, if present.
+func removeCondition(status *habv1beta1.HabitatStatus, t habv1beta1.HabitatConditionType) {
+	var conditions []habv1beta1.HabitatCondition
+	for _, c := range status.Conditions {
+		if c.Type != t {
+			conditions = append(conditions, c)
+		}
+	}
+
+	status.Conditions = conditions
+}
+
+// getCondition returns the condition of type t from status, or nil if not
+// present.
+func getCondition(status habv1beta1.HabitatStatus, t habv1beta1.HabitatConditionType) *habv1beta1.HabitatCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == t {
+			return &status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// newCondition returns a condition of type t, with a status of True if ok is
+// true and False otherwise.
+func newCondition(t habv1beta1.HabitatConditionType, ok bool, reason, message string) habv1beta1.HabitatCondition {
+	status := apiv1.ConditionFalse
+	if ok {
+		status = apiv1.ConditionTrue
+	}
+
+	return habv1beta1.HabitatCondition{
+		Type:    t,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// updateStatus writes the status of the Habitat h to the API server, unless it
+// is unchanged from oldStatus.
+func (hc *HabitatController) updateStatus(h *habv1beta1.Habitat, oldStatus habv1beta1.HabitatStatus) error {
+	if reflect.DeepEqual(h.Status, oldStatus) {
+		return nil
+	}
+
+	// There is no status subresource, so the whole object is updated. This
+	// doesn't cause a new reconciliation, as only changes to the spec do.
+	return hc.config.HabitatClient.Put().
+		Namespace(h.Namespace).
+		Resource(habv1beta1.HabitatResourcePlural).
+		Name(h.Name).
+		Body(h).
+		Do().
+		Into(&habv1beta1.Habitat{})
+}
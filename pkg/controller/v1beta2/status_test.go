@@ -0,0 +1,63 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+	"time"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+	status := habv1beta1.HabitatStatus{
+		Conditions: []habv1beta1.HabitatCondition{
+			{
+				Type:               habv1beta1.HabitatConditionBindsResolved,
+				Status:             apiv1.ConditionFalse,
+				LastTransitionTime: past,
+				Message:            "old",
+			},
+		},
+	}
+
+	// Same status: the transition time is preserved, the message is updated.
+	setCondition(&status, newCondition(habv1beta1.HabitatConditionBindsResolved, false, "UnresolvedBinds", "new"))
+	c := getCondition(status, habv1beta1.HabitatConditionBindsResolved)
+	if c == nil || !c.LastTransitionTime.Equal(&past) || c.Message != "new" {
+		t.Errorf("setCondition() with unchanged status = %+v", c)
+	}
+
+	// Different status: the transition time is updated.
+	setCondition(&status, newCondition(habv1beta1.HabitatConditionBindsResolved, true, "BindsResolved", ""))
+	c = getCondition(status, habv1beta1.HabitatConditionBindsResolved)
+	if c == nil || c.LastTransitionTime.Equal(&past) || c.Status != apiv1.ConditionTrue {
+		t.Errorf("setCondition() with changed status = %+v", c)
+	}
+
+	// New condition types are appended.
+	setCondition(&status, newCondition(habv1beta1.HabitatConditionBindsReady, true, "BindsReady", ""))
+	if len(status.Conditions) != 2 {
+		t.Errorf("setCondition() with new type: got %d conditions, want 2", len(status.Conditions))
+	}
+
+	removeCondition(&status, habv1beta1.HabitatConditionBindsResolved)
+	if getCondition(status, habv1beta1.HabitatConditionBindsResolved) != nil || len(status.Conditions) != 1 {
+		t.Errorf("removeCondition() = %+v", status.Conditions)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// authTokenEnvVar is the environment variable the supervisor reads the event
+	// stream token from.
+	authTokenEnvVar = "HAB_AUTH_TOKEN"
+
+	// shutdownGracePeriod is the time given to the supervisor to exit, on top of
+	// the shutdown timeout of the service.
+	shutdownGracePeriod = 10
+	// defaultTerminationGracePeriod is the termination grace period Kubernetes
+	// assigns to Pods by default.
+	defaultTerminationGracePeriod = 30
+)
+
+// managedFlags are the supervisor flags set by the operator, which can't be
+// passed through ExtraArgs.
+var managedFlags = []string{
+	"--group",
+	"--channel",
+	"--topology",
+	"--peer-watch-file",
+	"--peer",
+	"--bind",
+	"--ring",
+	"--strategy",
+	"--update-condition",
+	"--binding-mode",
+	"--health-check-interval",
+	"--shutdown-timeout",
+	"--application",
+	"--environment",
+	"--config-from",
+	"--listen-gossip",
+	"--listen-http",
+	"--org",
+	"--event-stream-application",
+	"--event-stream-environment",
+	"--event-stream-url",
+	"--event-stream-site",
+	"--event-stream-connect-timeout",
+	"--event-stream-token",
+}
+
+// supervisorArgs returns the supervisor arguments corresponding to the typed
+// supervisor and service settings of hs, followed by its ExtraArgs.
+func supervisorArgs(hs *habv1beta1.V1beta2) []string {
+	var args []string
+
+	s := hs.Service
+	if s.Strategy != nil {
+		args = append(args, "--strategy", string(*s.Strategy))
+	}
+	if s.UpdateCondition != nil {
+		args = append(args, "--update-condition", string(*s.UpdateCondition))
+	}
+	if s.BindingMode != nil {
+		args = append(args, "--binding-mode", string(*s.BindingMode))
+	}
+	if s.HealthCheckIntervalSeconds != nil {
+		args = append(args, "--health-check-interval", strconv.Itoa(int(*s.HealthCheckIntervalSeconds)))
+	}
+	if s.ShutdownTimeoutSeconds != nil {
+		args = append(args, "--shutdown-timeout", strconv.Itoa(int(*s.ShutdownTimeoutSeconds)))
+	}
+	if s.Application != nil && s.Environment != nil {
+		args = append(args,
+			"--application", *s.Application,
+			"--environment", *s.Environment)
+	}
+	if s.ConfigFrom != nil {
+		args = append(args, "--config-from", *s.ConfigFrom)
+	}
+
+	sup := hs.Supervisor
+	if sup == nil {
+		return args
+	}
+
+	if sup.ListenGossip != nil {
+		args = append(args, "--listen-gossip", *sup.ListenGossip)
+	}
+	if sup.ListenHTTP != nil {
+		args = append(args, "--listen-http", *sup.ListenHTTP)
+	}
+	if sup.Org != nil {
+		args = append(args, "--org", *sup.Org)
+	}
+
+	if es := sup.EventStream; es != nil {
+		args = append(args,
+			"--event-stream-application", es.Application,
+			"--event-stream-environment", es.Environment,
+			"--event-stream-url", es.URL)
+
+		if es.Site != nil {
+			args = append(args, "--event-stream-site", *es.Site)
+		}
+		if es.ConnectTimeoutSeconds != nil {
+			args = append(args, "--event-stream-connect-timeout", strconv.Itoa(int(*es.ConnectTimeoutSeconds)))
+		}
+	}
+
+	return append(args, sup.ExtraArgs...)
+}
+
+// supervisorEnv returns the environment of the supervisor container: the
+// environment variables of hs, plus the ones needed by the supervisor settings.
+func supervisorEnv(hs *habv1beta1.V1beta2) []apiv1.EnvVar {
+	if hs.Supervisor == nil || hs.Supervisor.EventStream == nil {
+		return hs.Env
+	}
+
+	// Copy the environment, so that the Habitat object isn't modified.
+	env := make([]apiv1.EnvVar, len(hs.Env), len(hs.Env)+1)
+	copy(env, hs.Env)
+
+	return append(env, apiv1.EnvVar{
+		Name: authTokenEnvVar,
+		ValueFrom: &apiv1.EnvVarSource{
+			SecretKeyRef: hs.Supervisor.EventStream.TokenSecretRef.DeepCopy(),
+		},
+	})
+}
+
+// terminationGracePeriod returns the termination grace period of the Pods
+// running the service, or nil if the default one is long enough.
+func terminationGracePeriod(s habv1beta1.ServiceV1beta2) *int64 {
+	if s.ShutdownTimeoutSeconds == nil {
+		return nil
+	}
+
+	period := int64(*s.ShutdownTimeoutSeconds) + shutdownGracePeriod
+	if period <= defaultTerminationGracePeriod {
+		return nil
+	}
+
+	return &period
+}
+
+// validateSupervisor validates the supervisor and service settings of spec
+// which are passed to the supervisor as flags.
+func validateSupervisor(spec *habv1beta1.V1beta2) error {
+	s := spec.Service
+
+	if v := s.Strategy; v != nil {
+		switch *v {
+		case habv1beta1.UpdateStrategyNone, habv1beta1.UpdateStrategyAtOnce, habv1beta1.UpdateStrategyRolling:
+		default:
+			return fmt.Errorf("unknown update strategy: %s", *v)
+		}
+	}
+
+	if v := s.UpdateCondition; v != nil {
+		switch *v {
+		case habv1beta1.UpdateConditionLatest, habv1beta1.UpdateConditionTrackChannel:
+		default:
+			return fmt.Errorf("unknown update condition: %s", *v)
+		}
+	}
+
+	if v := s.BindingMode; v != nil {
+		switch *v {
+		case habv1beta1.BindingModeStrict, habv1beta1.BindingModeRelaxed:
+		default:
+			return fmt.Errorf("unknown binding mode: %s", *v)
+		}
+	}
+
+	if v := s.HealthCheckIntervalSeconds; v != nil && *v <= 0 {
+		return fmt.Errorf("healthCheckIntervalSeconds must be positive: %d", *v)
+	}
+
+	if v := s.ShutdownTimeoutSeconds; v != nil && *v < 0 {
+		return fmt.Errorf("shutdownTimeoutSeconds must not be negative: %d", *v)
+	}
+
+	if (s.Application == nil) != (s.Environment == nil) {
+		return fmt.Errorf("application and environment must be specified together")
+	}
+
+	if v := s.ConfigFrom; v != nil && !path.IsAbs(*v) {
+		return fmt.Errorf("configFrom must be an absolute path: %s", *v)
+	}
+
+	sup := spec.Supervisor
+	if sup == nil {
+		return nil
+	}
+
+	if v := sup.ListenGossip; v != nil {
+		if err := validateListenAddress(*v); err != nil {
+			return fmt.Errorf("invalid listenGossip: %v", err)
+		}
+	}
+
+	if v := sup.ListenHTTP; v != nil {
+		if err := validateListenAddress(*v); err != nil {
+			return fmt.Errorf("invalid listenHTTP: %v", err)
+		}
+	}
+
+	if v := sup.Org; v != nil && *v == "" {
+		return fmt.Errorf("org must not be empty")
+	}
+
+	if es := sup.EventStream; es != nil {
+		if es.Application == "" || es.Environment == "" || es.URL == "" {
+			return fmt.Errorf("eventStream must specify application, environment and url")
+		}
+
+		if es.TokenSecretRef.Name == "" || es.TokenSecretRef.Key == "" {
+			return fmt.Errorf("eventStream must specify the name and key of tokenSecretRef")
+		}
+
+		if v := es.ConnectTimeoutSeconds; v != nil && *v < 0 {
+			return fmt.Errorf("eventStream connectTimeoutSeconds must not be negative: %d", *v)
+		}
+	}
+
+	for _, arg := range sup.ExtraArgs {
+		if flag := managedFlag(arg); flag != "" {
+			return fmt.Errorf("extraArgs can't set %s, which is managed by the operator", flag)
+		}
+	}
+
+	return nil
+}
+
+// managedFlag returns the managed flag set by arg, if any.
+func managedFlag(arg string) string {
+	name := strings.SplitN(arg, "=", 2)[0]
+	for _, f := range managedFlags {
+		if name == f {
+			return f
+		}
+	}
+
+	return ""
+}
+
+// validateListenAddress checks that addr is in the `ip:port` format.
+func validateListenAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid IP address: %q", host)
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid port: %q", port)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func int32ToPtr(i int32) *int32 {
+	return &i
+}
+
+func TestSupervisorArgs(t *testing.T) {
+	strategy := habv1beta1.UpdateStrategyRolling
+	bindingMode := habv1beta1.BindingModeRelaxed
+
+	hs := &habv1beta1.V1beta2{
+		Service: habv1beta1.ServiceV1beta2{
+			Strategy:               &strategy,
+			BindingMode:            &bindingMode,
+			ShutdownTimeoutSeconds: int32ToPtr(60),
+			Application:            strToPtr("shop"),
+			Environment:            strToPtr("prod"),
+		},
+		Supervisor: &habv1beta1.Supervisor{
+			ListenHTTP: strToPtr("0.0.0.0:9000"),
+			EventStream: &habv1beta1.EventStream{
+				Application: "shop",
+				Environment: "prod",
+				URL:         "nats.example.com:4222",
+				TokenSecretRef: apiv1.SecretKeySelector{
+					LocalObjectReference: apiv1.LocalObjectReference{Name: "automate"},
+					Key:                  "token",
+				},
+			},
+			ExtraArgs: []string{"--no-color"},
+		},
+	}
+
+	want := []string{
+		"--strategy", "rolling",
+		"--binding-mode", "relaxed",
+		"--shutdown-timeout", "60",
+		"--application", "shop",
+		"--environment", "prod",
+		"--listen-http", "0.0.0.0:9000",
+		"--event-stream-application", "shop",
+		"--event-stream-environment", "prod",
+		"--event-stream-url", "nats.example.com:4222",
+		"--no-color",
+	}
+	if got := supervisorArgs(hs); !reflect.DeepEqual(got, want) {
+		t.Errorf("supervisorArgs() = %v, want %v", got, want)
+	}
+
+	env := supervisorEnv(hs)
+	if len(env) != 1 || env[0].Name != authTokenEnvVar || env[0].ValueFrom.SecretKeyRef.Name != "automate" {
+		t.Errorf("supervisorEnv() = %+v", env)
+	}
+
+	if got := terminationGracePeriod(hs.Service); got == nil || *got != 70 {
+		t.Errorf("terminationGracePeriod() = %v, want 70", got)
+	}
+}
+
+func TestValidateSupervisor(t *testing.T) {
+	badStrategy := habv1beta1.UpdateStrategy("sometimes")
+
+	tests := []struct {
+		name    string
+		spec    habv1beta1.V1beta2
+		wantErr bool
+	}{
+		{
+			name: "no settings",
+		},
+		{
+			name: "unknown strategy",
+			spec: habv1beta1.V1beta2{
+				Service: habv1beta1.ServiceV1beta2{Strategy: &badStrategy},
+			},
+			wantErr: true,
+		},
+		{
+			name: "application without environment",
+			spec: habv1beta1.V1beta2{
+				Service: habv1beta1.ServiceV1beta2{Application: strToPtr("shop")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative config-from path",
+			spec: habv1beta1.V1beta2{
+				Service: habv1beta1.ServiceV1beta2{ConfigFrom: strToPtr("config")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid listen address",
+			spec: habv1beta1.V1beta2{
+				Supervisor: &habv1beta1.Supervisor{ListenGossip: strToPtr("0.0.0.0:9638")},
+			},
+		},
+		{
+			name: "listen address without IP",
+			spec: habv1beta1.V1beta2{
+				Supervisor: &habv1beta1.Supervisor{ListenGossip: strToPtr(":9638")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "extra args setting a managed flag",
+			spec: habv1beta1.V1beta2{
+				Supervisor: &habv1beta1.Supervisor{ExtraArgs: []string{"--topology=leader"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "event stream without token",
+			spec: habv1beta1.V1beta2{
+				Supervisor: &habv1beta1.Supervisor{
+					EventStream: &habv1beta1.EventStream{
+						Application: "shop",
+						Environment: "prod",
+						URL:         "nats.example.com:4222",
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSupervisor(&tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSupervisor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
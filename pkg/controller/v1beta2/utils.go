@@ -57,6 +57,47 @@ func validateCustomObject(h habv1beta1.Habitat) error {
 		return fmt.Errorf("unknown topology: %s", spec.Service.Topology)
 	}
 
+	if c := spec.Service.Config; c != nil {
+		if _, err := renderUserTOML(nil, c); err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	}
+
+	if ref := spec.Service.ConfigMapRef; ref != nil && ref.Name == "" {
+		return fmt.Errorf("missing name in configMapRef")
+	}
+
+	if ref := spec.Service.FilesConfigMapRef; ref != nil && ref.Name == "" {
+		return fmt.Errorf("missing name in filesConfigMapRef")
+	}
+
+	if err := validateBinds(h); err != nil {
+		return err
+	}
+
+	switch filesMode(spec.Service) {
+	case habv1beta1.FilesModeCopy:
+	case habv1beta1.FilesModeMount:
+	default:
+		return fmt.Errorf("unknown files mode: %s", *spec.Service.FilesMode)
+	}
+
+	if err := validateSupervisor(spec); err != nil {
+		return err
+	}
+
+	if err := validatePorts(spec); err != nil {
+		return err
+	}
+
+	if err := validatePodDisruptionBudget(spec); err != nil {
+		return err
+	}
+
+	if err := validatePersistentVolumes(spec); err != nil {
+		return err
+	}
+
 	if rsn := spec.Service.RingSecretName; rsn != nil {
 		rsn := *rsn
 		ringParts := ringRegexp.FindStringSubmatch(rsn)
@@ -0,0 +1,324 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"fmt"
+	"time"
+
+	habv1beta1 "github.com/habitat-sh/habitat-operator/pkg/apis/habitat/v1beta1"
+
+	"github.com/go-kit/kit/log/level"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// expansionPollInterval is how often the progress of a volume expansion is
+// checked.
+const expansionPollInterval = 30 * time.Second
+
+// The annotations marking the default StorageClass, which is used by the
+// claims which don't name one. The beta one is still set by some clusters.
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// claimResize is a claim template whose requested storage changed.
+type claimResize struct {
+	template apiv1.PersistentVolumeClaim
+	oldSize  resource.Quantity
+	newSize  resource.Quantity
+}
+
+func claimSize(c apiv1.PersistentVolumeClaim) resource.Quantity {
+	return c.Spec.Resources.Requests[apiv1.ResourceStorage]
+}
+
+// claimResizes returns the claim templates of newSts whose requested storage
+// differs from the one of the template with the same name in oldSts.
+func claimResizes(oldSts, newSts *appsv1.StatefulSet) []claimResize {
+	oldTemplates := map[string]apiv1.PersistentVolumeClaim{}
+	for _, c := range oldSts.Spec.VolumeClaimTemplates {
+		oldTemplates[c.Name] = c
+	}
+
+	var resizes []claimResize
+	for _, c := range newSts.Spec.VolumeClaimTemplates {
+		old, ok := oldTemplates[c.Name]
+		if !ok {
+			continue
+		}
+
+		oldSize, newSize := claimSize(old), claimSize(c)
+		if oldSize.Cmp(newSize) != 0 {
+			resizes = append(resizes, claimResize{template: c, oldSize: oldSize, newSize: newSize})
+		}
+	}
+
+	return resizes
+}
+
+// setClaimSize sets the requested storage of the claim template named name
+// in sts to size.
+func setClaimSize(sts *appsv1.StatefulSet, name string, size resource.Quantity) {
+	for i := range sts.Spec.VolumeClaimTemplates {
+		c := &sts.Spec.VolumeClaimTemplates[i]
+		if c.Name == name {
+			c.Spec.Resources.Requests = c.Spec.Resources.Requests.DeepCopy()
+			c.Spec.Resources.Requests[apiv1.ResourceStorage] = size
+		}
+	}
+}
+
+// replicaCount returns the number of Pods of sts, or of its replacement with
+// count replicas, whichever is higher.
+func replicaCount(sts *appsv1.StatefulSet, count int) int {
+	if sts.Spec.Replicas != nil && int(*sts.Spec.Replicas) > count {
+		return int(*sts.Spec.Replicas)
+	}
+
+	return count
+}
+
+// expansionAllowed returns an error if the StorageClass of the claim template
+// c doesn't allow volume expansion. If the StorageClass can't be read, e.g.
+// because the operator isn't allowed to, the API server has the final say
+// when the claims are patched.
+func (hc *HabitatController) expansionAllowed(c apiv1.PersistentVolumeClaim) error {
+	sc, err := hc.claimStorageClass(c)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			level.Debug(hc.logger).Log("msg", "not allowed to read StorageClass, assuming it allows expansion", "claim", c.Name)
+			return nil
+		}
+
+		return err
+	}
+
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return fmt.Errorf("StorageClass %q doesn't allow volume expansion", sc.Name)
+	}
+
+	return nil
+}
+
+// claimStorageClass returns the StorageClass of the claim template c: the one
+// it names, or the default StorageClass of the cluster if it names none.
+func (hc *HabitatController) claimStorageClass(c apiv1.PersistentVolumeClaim) (*storagev1.StorageClass, error) {
+	if c.Spec.StorageClassName != nil {
+		if *c.Spec.StorageClassName == "" {
+			return nil, fmt.Errorf("claim %q has no StorageClass", c.Name)
+		}
+
+		return hc.config.KubernetesClientset.StorageV1().StorageClasses().Get(*c.Spec.StorageClassName, metav1.GetOptions{})
+	}
+
+	classes, err := hc.config.KubernetesClientset.StorageV1().StorageClasses().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	sc := defaultStorageClass(classes.Items)
+	if sc == nil {
+		return nil, fmt.Errorf("claim %q has no StorageClass, and there is no default StorageClass", c.Name)
+	}
+
+	return sc, nil
+}
+
+// defaultStorageClass returns the StorageClass among classes marked as the
+// default, or nil if there is none.
+func defaultStorageClass(classes []storagev1.StorageClass) *storagev1.StorageClass {
+	for i := range classes {
+		sc := &classes[i]
+		if sc.Annotations[defaultStorageClassAnnotation] == "true" || sc.Annotations[betaDefaultStorageClassAnnotation] == "true" {
+			return sc
+		}
+	}
+
+	return nil
+}
+
+// reconcileVolumeExpansion expands the PersistentVolumeClaims of oldSts whose
+// size was increased in newSts. Changes which can't be applied, such as
+// shrinking a volume, are reverted in newSts and reported in the status of the
+// Habitat h. It returns true if the claim templates of oldSts changed, and the
+// StatefulSet needs to be recreated.
+func (hc *HabitatController) reconcileVolumeExpansion(h *habv1beta1.Habitat, oldSts, newSts *appsv1.StatefulSet) (bool, error) {
+	resizes := claimResizes(oldSts, newSts)
+	if len(resizes) == 0 {
+		return false, hc.checkVolumeExpansion(h, oldSts)
+	}
+
+	recreate := false
+	for _, r := range resizes {
+		var reason, msg string
+		if r.newSize.Cmp(r.oldSize) < 0 {
+			reason = "ShrinkNotSupported"
+			msg = fmt.Sprintf("Persistent volume %q can't be shrunk from %s to %s", r.template.Name, r.oldSize.String(), r.newSize.String())
+		} else if !hc.featureEnabled(FeatureGateVolumeExpansion) {
+			reason = "ExpansionDisabled"
+			msg = fmt.Sprintf("Persistent volume %q can't be expanded: the %s feature gate of the operator is disabled", r.template.Name, FeatureGateVolumeExpansion)
+		} else if err := hc.expansionAllowed(r.template); err != nil {
+			reason = "ExpansionNotAllowed"
+			msg = fmt.Sprintf("Persistent volume %q can't be expanded: %v", r.template.Name, err)
+		}
+
+		if reason != "" {
+			if c := getCondition(h.Status, habv1beta1.HabitatConditionVolumeExpansion); c == nil || c.Message != msg {
+				hc.recorder.Event(h, apiv1.EventTypeWarning, volumeExpansionFailed, msg)
+			}
+			setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionVolumeExpansion, false, reason, msg))
+			setClaimSize(newSts, r.template.Name, r.oldSize)
+			continue
+		}
+
+		if err := hc.expandClaims(oldSts, r.template, replicaCount(oldSts, h.Spec.V1beta2.Count)); err != nil {
+			return false, err
+		}
+
+		msg = fmt.Sprintf("Expanding persistent volume %q from %s to %s", r.template.Name, r.oldSize.String(), r.newSize.String())
+		hc.recorder.Event(h, apiv1.EventTypeNormal, volumeExpansionStarted, msg)
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionVolumeExpansion, false, "Expanding", msg))
+		recreate = true
+	}
+
+	return recreate, nil
+}
+
+// expandClaims sets the requested storage of the existing claims created from
+// the template c to the size requested by c.
+func (hc *HabitatController) expandClaims(sts *appsv1.StatefulSet, c apiv1.PersistentVolumeClaim, replicas int) error {
+	size := claimSize(c)
+	patch := []byte(fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, size.String()))
+	claims := hc.config.KubernetesClientset.CoreV1().PersistentVolumeClaims(sts.Namespace)
+
+	for ordinal := 0; ordinal < replicas; ordinal++ {
+		name := claimName(c, sts, ordinal)
+
+		pvc, err := claims.Get(name, metav1.GetOptions{})
+		if err != nil {
+			// The claim is created along with the Pod.
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return err
+		}
+
+		if current := claimSize(*pvc); current.Cmp(size) >= 0 {
+			continue
+		}
+
+		if _, err := claims.Patch(name, types.StrategicMergePatchType, patch); err != nil {
+			return err
+		}
+
+		level.Info(hc.logger).Log("msg", "expanding PersistentVolumeClaim", "name", name, "size", size.String())
+	}
+
+	return nil
+}
+
+// checkVolumeExpansion updates the progress of an ongoing volume expansion of
+// the Habitat h in its status.
+func (hc *HabitatController) checkVolumeExpansion(h *habv1beta1.Habitat, sts *appsv1.StatefulSet) error {
+	c := getCondition(h.Status, habv1beta1.HabitatConditionVolumeExpansion)
+	if c == nil || c.Status == apiv1.ConditionTrue {
+		return nil
+	}
+
+	// The spec no longer requests the size which couldn't be applied.
+	if c.Reason == "ShrinkNotSupported" || c.Reason == "ExpansionNotAllowed" || c.Reason == "ExpansionDisabled" {
+		removeCondition(&h.Status, habv1beta1.HabitatConditionVolumeExpansion)
+		return nil
+	}
+
+	claims := hc.config.KubernetesClientset.CoreV1().PersistentVolumeClaims(sts.Namespace)
+
+	total, expanded, pending := 0, 0, 0
+	for _, t := range sts.Spec.VolumeClaimTemplates {
+		size := claimSize(t)
+
+		for ordinal := 0; ordinal < replicaCount(sts, 0); ordinal++ {
+			pvc, err := claims.Get(claimName(t, sts, ordinal), metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				return err
+			}
+
+			total++
+
+			capacity := pvc.Status.Capacity[apiv1.ResourceStorage]
+			if capacity.Cmp(size) >= 0 {
+				expanded++
+				continue
+			}
+
+			for _, pc := range pvc.Status.Conditions {
+				if pc.Type == apiv1.PersistentVolumeClaimFileSystemResizePending && pc.Status == apiv1.ConditionTrue {
+					pending++
+				}
+			}
+		}
+	}
+
+	if expanded == total {
+		msg := "All persistent volumes have the requested size"
+		hc.recorder.Event(h, apiv1.EventTypeNormal, volumeExpansionDone, msg)
+		setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionVolumeExpansion, true, "Expanded", msg))
+		return nil
+	}
+
+	msg := fmt.Sprintf("%d of %d PersistentVolumeClaims expanded", expanded, total)
+	if pending > 0 {
+		msg = fmt.Sprintf("%s, %d waiting for their Pod to be restarted to resize the file system", msg, pending)
+	}
+	setCondition(&h.Status, newCondition(habv1beta1.HabitatConditionVolumeExpansion, false, "Expanding", msg))
+
+	// Claims don't belong to the Habitat, so changes to them aren't watched.
+	key := habitatKey(h)
+	hc.queue.AddAfter(key, expansionPollInterval)
+
+	return nil
+}
+
+// orphanStatefulSet deletes the StatefulSet sts, leaving its Pods and
+// PersistentVolumeClaims in place, so that it can be recreated with a
+// different immutable spec. The replacement is created once the deletion is
+// observed, and adopts the Pods.
+func (hc *HabitatController) orphanStatefulSet(sts *appsv1.StatefulSet) error {
+	orphan := metav1.DeletePropagationOrphan
+	err := hc.config.KubernetesClientset.AppsV1().StatefulSets(sts.Namespace).Delete(sts.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &orphan,
+		Preconditions:     &metav1.Preconditions{UID: &sts.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	level.Info(hc.logger).Log("msg", "deleted StatefulSet to recreate it, orphaning its Pods", "name", sts.Name)
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Chef Software Inc. and/or applicable contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta2
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newTestClaimTemplate(name, size string) apiv1.PersistentVolumeClaim {
+	c := apiv1.PersistentVolumeClaim{
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{
+					apiv1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+	c.Name = name
+
+	return c
+}
+
+func TestClaimResizes(t *testing.T) {
+	oldSts := &appsv1.StatefulSet{}
+	oldSts.Spec.VolumeClaimTemplates = []apiv1.PersistentVolumeClaim{
+		newTestClaimTemplate("data", "1Gi"),
+		newTestClaimTemplate("logs", "1Gi"),
+	}
+
+	newSts := &appsv1.StatefulSet{}
+	newSts.Spec.VolumeClaimTemplates = []apiv1.PersistentVolumeClaim{
+		// The same size, written differently.
+		newTestClaimTemplate("data", "1024Mi"),
+		newTestClaimTemplate("logs", "2Gi"),
+		// New templates aren't resizes.
+		newTestClaimTemplate("backups", "5Gi"),
+	}
+
+	resizes := claimResizes(oldSts, newSts)
+	if len(resizes) != 1 || resizes[0].template.Name != "logs" {
+		t.Fatalf("claimResizes() = %+v, want a single resize of logs", resizes)
+	}
+
+	want := resource.MustParse("1Gi")
+	setClaimSize(newSts, "logs", want)
+	if got := claimSize(newSts.Spec.VolumeClaimTemplates[1]); got.Cmp(want) != 0 {
+		t.Errorf("setClaimSize() size = %s, want %s", got.String(), want.String())
+	}
+	if len(claimResizes(oldSts, newSts)) != 0 {
+		t.Errorf("claimResizes() after setClaimSize() = %+v, want none", claimResizes(oldSts, newSts))
+	}
+}
+
+func TestReplicaCount(t *testing.T) {
+	replicas := int32(3)
+	sts := &appsv1.StatefulSet{}
+	sts.Spec.Replicas = &replicas
+
+	if got := replicaCount(sts, 2); got != 3 {
+		t.Errorf("replicaCount() = %d, want 3", got)
+	}
+	if got := replicaCount(sts, 5); got != 5 {
+		t.Errorf("replicaCount() = %d, want 5", got)
+	}
+}
+
+func TestDefaultStorageClass(t *testing.T) {
+	standard := storagev1.StorageClass{}
+	standard.Name = "standard"
+	fast := storagev1.StorageClass{}
+	fast.Name = "fast"
+	fast.Annotations = map[string]string{defaultStorageClassAnnotation: "true"}
+	beta := storagev1.StorageClass{}
+	beta.Name = "beta"
+	beta.Annotations = map[string]string{betaDefaultStorageClassAnnotation: "true"}
+
+	if sc := defaultStorageClass([]storagev1.StorageClass{standard}); sc != nil {
+		t.Errorf("defaultStorageClass() = %s, want none", sc.Name)
+	}
+	if sc := defaultStorageClass([]storagev1.StorageClass{standard, fast}); sc == nil || sc.Name != "fast" {
+		t.Errorf("defaultStorageClass() = %v, want fast", sc)
+	}
+	if sc := defaultStorageClass([]storagev1.StorageClass{beta, standard}); sc == nil || sc.Name != "beta" {
+		t.Errorf("defaultStorageClass() = %v, want beta", sc)
+	}
+}
@@ -61,7 +61,7 @@ func TestRolesAndClusterRolesSync(t *testing.T) {
 	clusterRolesRules, err := extractRulesFromClusterRoles("examples/rbac/rbac.yml")
 	require.NoError(t, err, "extracting Rules from ClusterRole failed for rbac in examples")
 
-	// Now we will just remove the two roles that this ClusterRole has extra and try to match
+	// Now we will just remove the three roles that this ClusterRole has extra and try to match
 	//    rules:
 	//    - apiGroups:
 	//      - apiextensions.k8s.io
@@ -73,15 +73,20 @@ func TestRolesAndClusterRolesSync(t *testing.T) {
 	//      - namespaces
 	//      verbs: ["list"]
 	//    - apiGroups:
+	//      - storage.k8s.io
+	//      resources:
+	//      - storageclasses
+	//      verbs: ["get", "list"]
+	//    - apiGroups:
 	//      - habitat.sh
 	//      resources:
 	//      - habitats
 	//      verbs: ["get", "list", "watch", "create", "update", "patch", "delete"]
 	//
-	// The first two rules for CRD and Namespaces are extra permissions that ClusterRole has
-	// rest of the permissions are same for Role and ClusterRole so we just remove those two
+	// The first three rules for CRD, Namespaces and StorageClasses are extra permissions that ClusterRole has
+	// rest of the permissions are same for Role and ClusterRole so we just remove those three
 	// and match if other roles match
-	matchingClusterRoleRules := clusterRolesRules[2:]
+	matchingClusterRoleRules := clusterRolesRules[3:]
 	require.Equal(t, rolesRules, matchingClusterRoleRules, "Role and ClusterRole are not equal")
 	t.Log("Roles and ClusterRoles are in sync")
 }